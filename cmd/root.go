@@ -1,71 +1,497 @@
 package cmd
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/confluentinc/schema-deletion-tool/pkg"
 	"github.com/spf13/cobra"
 )
 
 func run(cmd *cobra.Command, _ []string) error {
-	var subjects []string
 	subject, all, err := pkg.ValidateParams(cmd)
 	if err != nil {
 		return err
 	}
-	if all {
-		subjects, err = pkg.GetAllEligibleSubjects()
+	// The run is cancelled on Ctrl-C.
+	runCtx := cmd.Context()
+	finalize, err := cmd.Flags().GetBool("finalize")
+	if err != nil {
+		return err
+	}
+	environments, currentEnvironment, err := getEnvironments(runCtx, cmd)
+	if err != nil {
+		return err
+	}
+	store, err := loadQuarantineStore(cmd)
+	if err != nil {
+		return err
+	}
+	store.SetDefaultEnvironment(currentEnvironment)
+	var due []pkg.QuarantineEntry
+	if finalize {
+		period, err := cmd.Flags().GetDuration("quarantine-period")
 		if err != nil {
 			return err
 		}
-	} else {
-		subjects = []string{subject}
+		due = store.Due(period, time.Now())
+		if len(due) == 0 {
+			fmt.Printf("No quarantined schemas in %s have completed the quarantine period of %s.\n", store.Path, period)
+			return nil
+		}
 	}
 
-	topics := pkg.ExtractTopicFromSubject(subjects)
-
 	// Don't display usage messages after parameters are validated.
 	cmd.SilenceUsage = true
 
-	configFile, err := cmd.Flags().GetString("config-file")
+	ctx, err := newContext(cmd)
 	if err != nil {
 		return err
 	}
+	if ctx.Resume != nil && len(environments) > 1 {
+		return errors.New("--resume can only be used when cleaning up a single environment")
+	}
+	if len(environments) == 1 {
+		envCtx := ctx.ForEnvironment(environments[0])
+		_, err = cleanUpEnvironment(runCtx, cmd, envCtx, store, subject, all, due)
+		return err
+	}
+
+	// Clean up each environment in its own section, and sum them up at the end.
+	var reports []pkg.EnvironmentReport
+	var failed int
+	for _, environment := range environments {
+		fmt.Printf("%s=== Environment %s ===%s\n", pkg.GREEN, environment, pkg.RESET)
+		envCtx := ctx.ForEnvironment(environment)
+		envCtx.ProgressFile = fmt.Sprintf("%s.%s", ctx.ProgressFile, environment)
+		report, err := cleanUpEnvironment(runCtx, cmd, envCtx, store, subject, all, due)
+		if err != nil {
+			if runCtx.Err() != nil {
+				return err
+			}
+			// A failure in one environment does not prevent cleaning up the others.
+			fmt.Printf("%sFailed to clean up environment %s: %v%s\n", pkg.RED, environment, err, pkg.RESET)
+			report.Result = err.Error()
+			failed++
+		}
+		reports = append(reports, report)
+	}
+	pkg.ReportEnvironments(reports)
+	if failed > 0 {
+		return fmt.Errorf("failed to clean up %d of %d environment(s)", failed, len(environments))
+	}
+	return nil
+}
+
+// newContext creates the context shared by all the cleaned up environments from the options.
+func newContext(cmd *cobra.Command) (*pkg.Context, error) {
+	configFile, err := cmd.Flags().GetString("config-file")
+	if err != nil {
+		return nil, err
+	}
 	ctx, err := pkg.NewContext(configFile)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	ctx.RecheckPolicy, err = cmd.Flags().GetString("recheck-policy")
+	if err != nil {
+		return nil, err
+	}
+	if ctx.RecheckPolicy != pkg.RecheckDrop && ctx.RecheckPolicy != pkg.RecheckAbort {
+		return nil, fmt.Errorf("--recheck-policy must be either %q or %q", pkg.RecheckDrop, pkg.RecheckAbort)
+	}
+	ctx.IsolationLevel, err = cmd.Flags().GetString("isolation-level")
+	if err != nil {
+		return nil, err
+	}
+	if ctx.IsolationLevel != pkg.ReadCommitted && ctx.IsolationLevel != pkg.ReadUncommitted {
+		return nil, fmt.Errorf("--isolation-level must be either %q or %q", pkg.ReadCommitted, pkg.ReadUncommitted)
+	}
+	ctx.WatchWindow, err = cmd.Flags().GetDuration("watch")
+	if err != nil {
+		return nil, err
+	}
+	ctx.AutoRollback, err = cmd.Flags().GetBool("auto-rollback")
+	if err != nil {
+		return nil, err
+	}
+	ctx.ScanAllTopics, err = cmd.Flags().GetBool("scan-all-topics")
+	if err != nil {
+		return nil, err
+	}
+	ctx.UsageIndexFile, err = cmd.Flags().GetString("usage-index")
+	if err != nil {
+		return nil, err
+	}
+	ctx.UsageIndexMaxAge, err = cmd.Flags().GetDuration("usage-index-max-age")
+	if err != nil {
+		return nil, err
+	}
+	ctx.DiscoverySamples, err = cmd.Flags().GetInt64("discover-topics")
+	if err != nil {
+		return nil, err
+	}
+	if ctx.DiscoverySamples < 0 {
+		return nil, fmt.Errorf("--discover-topics must not be negative")
+	}
+	ctx.ScanMirrors, err = cmd.Flags().GetBool("scan-mirrors")
+	if err != nil {
+		return nil, err
+	}
+	ctx.StaleAfter, err = cmd.Flags().GetDuration("stale-after")
+	if err != nil {
+		return nil, err
+	}
+	ctx.TopicTimeout, err = cmd.Flags().GetDuration("topic-timeout")
+	if err != nil {
+		return nil, err
+	}
+	ctx.ProgressFile, err = cmd.Flags().GetString("progress-file")
+	if err != nil {
+		return nil, err
+	}
+	if len(ctx.ProgressFile) == 0 {
+		ctx.ProgressFile, err = pkg.DefaultStateFile(pkg.ProgressStateFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	resumeFile, err := cmd.Flags().GetString("resume")
+	if err != nil {
+		return nil, err
+	}
+	if len(resumeFile) != 0 {
+		ctx.Resume, err = pkg.LoadProgress(resumeFile)
+		if err != nil {
+			return nil, err
+		}
+		// Keep checkpointing to the same file.
+		ctx.ProgressFile = resumeFile
+	}
+	contextClustersFile, err := cmd.Flags().GetString("context-clusters")
+	if err != nil {
+		return nil, err
+	}
+	if len(contextClustersFile) != 0 {
+		ctx.ContextClusters, err = pkg.LoadContextClusters(contextClustersFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	topicMappingFile, err := cmd.Flags().GetString("topic-mapping")
+	if err != nil {
+		return nil, err
+	}
+	if len(topicMappingFile) != 0 {
+		ctx.TopicMapping, err = pkg.LoadTopicMapping(topicMappingFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	topologyFile, err := cmd.Flags().GetString("topology")
+	if err != nil {
+		return nil, err
+	}
+	if len(topologyFile) != 0 {
+		ctx.Topology, err = pkg.LoadTopology(topologyFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ctx, nil
+}
+
+// getEnvironments returns the environments to clean up, along with the environment in use by the Confluent CLI.
+func getEnvironments(runCtx context.Context, cmd *cobra.Command) ([]string, string, error) {
+	allEnvironments, err := cmd.Flags().GetBool("all-environments")
+	if err != nil {
+		return nil, "", err
+	}
+	environments, err := cmd.Flags().GetStringSlice("environment")
+	if err != nil {
+		return nil, "", err
+	}
+	available, current, err := pkg.ListEnvironments(runCtx)
+	if err != nil {
+		return nil, "", err
+	}
+	if allEnvironments {
+		environments = nil
+		for _, environment := range available {
+			environments = append(environments, environment.ID)
+		}
+	}
+	if len(environments) == 0 {
+		if len(current) == 0 {
+			return nil, "", errors.New(`no environment in use, run "confluent environment use <environment>" or use --environment`)
+		}
+		environments = []string{current}
+	}
+	return environments, current, nil
+}
+
+// cleanUpEnvironment cleans up the subjects of an environment, one schema context after the other.
+func cleanUpEnvironment(runCtx context.Context, cmd *cobra.Command, ctx *pkg.Context, store *pkg.QuarantineStore, subject string, all bool, due []pkg.QuarantineEntry) (pkg.EnvironmentReport, error) {
+	report := pkg.EnvironmentReport{Environment: ctx.Environment, Result: "no schemas deleted"}
+	finalize, err := cmd.Flags().GetBool("finalize")
+	if err != nil {
+		return report, err
+	}
+	subjectPrefix, err := getSubjectPrefix(cmd)
+	if err != nil {
+		return report, err
+	}
+	var subjects []string
+	if finalize {
+		due = pkg.DueInEnvironment(due, ctx.Environment)
+		if len(due) == 0 {
+			fmt.Printf("No quarantined schemas of environment %s have completed the quarantine period.\n", ctx.Environment)
+			return report, nil
+		}
+		subjects = pkg.QuarantinedSubjects(due)
+	} else if all {
+		subjects, err = pkg.GetAllEligibleSubjects(runCtx, ctx, subjectPrefix)
+		if err != nil {
+			return report, err
+		}
+	} else if strings.HasPrefix(subject, pkg.CONTEXT_PREFIX) {
+		subjects = []string{subject}
+	} else {
+		subjects = []string{subjectPrefix + subject}
 	}
 	ctx.Subjects = subjects
-	ctx.Topics = topics
+	ctx.Topics = ctx.TopicsOf(subjects)
 
-	// Traverse all clusters in the current environment and prompt for credentials.
-	err = pkg.ListClusters(ctx)
+	// Find the subjects exported to other Schema Registries, whose schemas may be used by topics there.
+	ctx.Exports, err = pkg.ListExportedSubjects(runCtx, ctx)
 	if err != nil {
-		return err
+		if runCtx.Err() != nil {
+			return report, runCtx.Err()
+		}
+		fmt.Printf("%sFailed to list schema exporters, every subject is protected as it may be exported: %v%s\n", pkg.RED, err, pkg.RESET)
+		ctx.Exports = pkg.UnknownExports(subjects)
+	}
+
+	// Traverse all clusters in the environment and prompt for credentials.
+	err = pkg.ListClusters(runCtx, ctx)
+	if err != nil {
+		return report, err
+	}
+
+	// Sample every topic once for all the schema contexts, to discover the topics using their schemas.
+	if ctx.DiscoverySamples > 0 {
+		scanCtx, cancel, err := withScanTimeout(runCtx, cmd)
+		if err != nil {
+			return report, err
+		}
+		err = pkg.SampleTopics(scanCtx, ctx)
+		cancel()
+		if err != nil {
+			return report, err
+		}
+	}
+
+	// Scan every topic once for all the schema contexts, which take the scans of the topics of their clusters.
+	contexts, groups := pkg.GroupSubjectsByContext(subjects)
+	if ctx.ScanAllTopics && len(contexts) > 1 {
+		scanCtx, cancel, err := withScanTimeout(runCtx, cmd)
+		if err != nil {
+			return report, err
+		}
+		err = pkg.ScanEnvironmentTopics(scanCtx, ctx)
+		cancel()
+		if err != nil {
+			return report, err
+		}
+	}
+
+	// Clean up each schema context on its own, as schema IDs are only unique within a context.
+	for _, schemaContext := range contexts {
+		contextCtx := ctx.ForSchemaContext(schemaContext, groups[schemaContext])
+		if len(contexts) > 1 {
+			fmt.Printf("%sCleaning up %d subject(s) of schema context %s...%s\n", pkg.GREEN, len(contextCtx.Subjects), schemaContext, pkg.RESET)
+			contextCtx.ProgressFile = fmt.Sprintf("%s.%s", ctx.ProgressFile, strings.TrimPrefix(schemaContext, pkg.DEFAULT_CONTEXT))
+			if schemaContext == pkg.DEFAULT_CONTEXT {
+				contextCtx.ProgressFile = ctx.ProgressFile
+			}
+		}
+		if len(contextCtx.Clusters) == 0 {
+			fmt.Printf("%sNone of the selected clusters serves schema context %s, its topics will not be scanned.%s\n",
+				pkg.RED, schemaContext, pkg.RESET)
+		}
+		outcome, err := cleanUp(runCtx, cmd, contextCtx, store, pkg.DueInSubjects(due, ctx.Environment, contextCtx.Subjects))
+		report.Record(contextCtx, outcome)
+		if err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// withScanTimeout returns the context limiting listing and scanning to the timeout, if any.
+func withScanTimeout(runCtx context.Context, cmd *cobra.Command) (context.Context, context.CancelFunc, error) {
+	timeout, err := cmd.Flags().GetDuration("timeout")
+	if err != nil {
+		return nil, nil, err
+	}
+	if timeout <= 0 {
+		return runCtx, func() {}, nil
+	}
+	scanCtx, cancel := context.WithTimeout(runCtx, timeout)
+	return scanCtx, cancel, nil
+}
+
+// cleanUp scans the topics of the subjects of a schema context, and deletes the schemas selected among the unused
+// ones. During a finalize run, it hard deletes the due quarantined schemas instead.
+func cleanUp(runCtx context.Context, cmd *cobra.Command, ctx *pkg.Context, store *pkg.QuarantineStore, due []pkg.QuarantineEntry) (pkg.DeletionOutcome, error) {
+	var outcome pkg.DeletionOutcome
+	finalize, err := cmd.Flags().GetBool("finalize")
+	if err != nil {
+		return outcome, err
+	}
+	if ctx.Resume != nil {
+		if err = ctx.Resume.Validate(ctx); err != nil {
+			return outcome, err
+		}
+	}
+	// The timeout only limits listing and scanning, not the prompts and deletions that follow.
+	scanCtx, cancel, err := withScanTimeout(runCtx, cmd)
+	if err != nil {
+		return outcome, err
 	}
+	defer cancel()
 
 	// Retrieve all schemas under the target subject(s).
-	schemas, err := pkg.GetAllSchemas(ctx)
+	schemas, err := pkg.GetAllSchemas(scanCtx, ctx)
 	if err != nil {
-		return err
+		return outcome, err
+	}
+
+	// Scan the sampled topics carrying schema IDs of the subjects too.
+	if ctx.DiscoverySamples > 0 {
+		pkg.DiscoverTopics(ctx, schemas)
 	}
 
-	// Filter out all eligible topics and scan for active schemas.
-	activeSchemas, err := pkg.ListAndScanTopics(ctx)
+	var activeSchemas map[int32]int
+	if len(ctx.UsageIndexFile) > 0 && !ctx.ScanAllTopics {
+		// Take the active schemas from the usage index built by a previous scan of all topics.
+		activeSchemas, err = pkg.ReuseUsageIndex(scanCtx, ctx)
+		if err != nil {
+			return outcome, err
+		}
+	} else {
+		if ctx.TopicScans != nil {
+			// Take the active schemas from the scan of every topic of the environment.
+			activeSchemas = pkg.ShareTopicScans(ctx)
+		} else {
+			// Filter out all eligible topics and scan for active schemas.
+			activeSchemas, err = pkg.ListAndScanTopics(scanCtx, ctx)
+			if err != nil {
+				return outcome, err
+			}
+		}
+		if ctx.ScanAllTopics && len(ctx.UsageIndexFile) > 0 {
+			if err = pkg.SaveUsageIndex(ctx); err != nil {
+				return outcome, err
+			}
+		}
+	}
+	pkg.ReportSchemaUsage(ctx, schemas)
+	if err = pkg.ReportDataHygiene(scanCtx, ctx, schemas); err != nil {
+		return outcome, err
+	}
+	if err = pkg.ReportSharedSchemaIDs(scanCtx, ctx, schemas); err != nil {
+		return outcome, err
+	}
+	if ctx.IsolationLevel == pkg.ReadUncommitted {
+		pkg.ReportUncommittedUsage(ctx, schemas)
+	}
+	consumerGroupPolicy, err := cmd.Flags().GetBool("consumer-group-policy")
 	if err != nil {
-		return err
+		return outcome, err
+	}
+	if consumerGroupPolicy {
+		// Only protect schemas used by messages that some consumer group has not consumed yet.
+		activeSchemas, err = pkg.ApplyConsumerGroupPolicy(scanCtx, ctx, schemas, activeSchemas)
+		if err != nil {
+			return outcome, err
+		}
+	}
+
+	if finalize {
+		// Hard delete the quarantined schemas that have not reappeared in any topic.
+		return pkg.FinalizeQuarantine(runCtx, ctx, store, due, schemas, activeSchemas)
+	}
+
+	purge, err := cmd.Flags().GetBool("purge")
+	if err != nil {
+		return outcome, err
+	}
+	if purge {
+		// Only previously soft deleted schemas that are still unused are eligible for purging.
+		selection, stale, err := pkg.SelectDeletionCandidates(ctx, pkg.ProtectExportedSchemas(ctx, pkg.ProtectUnknownUsage(ctx, pkg.SoftDeletedSchemas(schemas))), activeSchemas)
+		if err != nil {
+			return outcome, err
+		}
+		selection, err = pkg.RecheckTopics(runCtx, ctx, selection)
+		if err != nil {
+			return outcome, err
+		}
+		return pkg.PurgeSchemas(runCtx, ctx, selection, stale, schemas)
 	}
 
 	// Prompt users to delete schemas they want to soft/hard delete.
-	selection, err := pkg.SelectDeletionCandidates(schemas, activeSchemas)
+	selection, stale, err := pkg.SelectDeletionCandidates(ctx, pkg.ProtectExportedSchemas(ctx, pkg.ProtectUnknownUsage(ctx, schemas)), activeSchemas)
 	if err != nil {
-		return err
+		return outcome, err
 	}
-	err = pkg.DeleteSchemas(selection)
+	// Make sure none of the selected schemas has been used since the topics were scanned.
+	selection, err = pkg.RecheckTopics(runCtx, ctx, selection)
 	if err != nil {
-		return err
+		return outcome, err
 	}
-	return nil
+	quarantine, err := cmd.Flags().GetBool("quarantine")
+	if err != nil {
+		return outcome, err
+	}
+	if quarantine {
+		return pkg.QuarantineSchemas(runCtx, ctx, selection, schemas, store)
+	}
+	return pkg.DeleteSchemas(runCtx, ctx, selection, stale, schemas)
+}
+
+// getSubjectPrefix returns the prefix of the subjects to clean up, based on the schema context options.
+func getSubjectPrefix(cmd *cobra.Command) (string, error) {
+	allContexts, err := cmd.Flags().GetBool("all-contexts")
+	if err != nil {
+		return "", err
+	}
+	if allContexts {
+		return pkg.ALL_CONTEXTS_PREFIX, nil
+	}
+	schemaContext, err := cmd.Flags().GetString("context")
+	if err != nil {
+		return "", err
+	}
+	return pkg.ContextPrefix(pkg.NormalizeContext(schemaContext)), nil
+}
+
+func loadQuarantineStore(cmd *cobra.Command) (*pkg.QuarantineStore, error) {
+	stateFile, err := cmd.Flags().GetString("state-file")
+	if err != nil {
+		return nil, err
+	}
+	if len(stateFile) == 0 {
+		stateFile, err = pkg.DefaultStateFile(pkg.QuarantineStateFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return pkg.LoadQuarantineStore(stateFile)
 }
 
 func Execute() {
@@ -78,9 +504,49 @@ func Execute() {
 
 	rootCmd.Flags().StringP("subject", "V", "", "Subject to clean up schemas from.")
 	rootCmd.Flags().Bool("all", false, "Clean up all eligible subjects.")
+	rootCmd.Flags().StringSlice("environment", nil, "Environment to clean up, repeatable. The environment in use by the Confluent CLI by default.")
+	rootCmd.Flags().Bool("all-environments", false, "Clean up every environment of the organization.")
+	rootCmd.Flags().String("context", "", "Schema context of the subjects to clean up. The default context by default.")
+	rootCmd.Flags().Bool("all-contexts", false, "With --all, clean up the eligible subjects of every schema context.")
+	rootCmd.Flags().String("context-clusters", "", "Path to a file mapping schema contexts to the clusters whose topics they serve.")
+	rootCmd.Flags().Int64("discover-topics", 0, "Sample this many of the most recent messages of each partition of every topic, and also scan the topics carrying schema IDs of the subjects.")
+	rootCmd.Flags().Bool("scan-all-topics", false, "Scan every readable topic of the selected clusters, which allows cleaning up subjects of any naming strategy.")
+	rootCmd.Flags().String("usage-index", "", "Path to the file where the usage index built with --scan-all-topics is saved, and reused from without it.")
+	rootCmd.Flags().Duration("usage-index-max-age", 24*time.Hour, "How old a usage index may be to be reused, zero for no limit.")
+	rootCmd.Flags().String("topic-mapping", "", "Path to a file declaring extra topics and topic name patterns of subjects, e.g. dead letter queues.")
+	rootCmd.Flags().String("topology", "", "Path to a file mapping subjects and schema contexts to clusters of other environments or self-managed clusters.")
+	rootCmd.Flags().Bool("purge", false, "Permanently delete soft deleted schemas that are still unused.")
+	rootCmd.Flags().Bool("quarantine", false, "Soft delete the selected schemas and record them for a later --finalize run.")
+	rootCmd.Flags().Bool("finalize", false, "Hard delete quarantined schemas that are past the quarantine period and still unused.")
+	rootCmd.Flags().Duration("quarantine-period", 7*24*time.Hour, "How long schemas stay quarantined before --finalize hard deletes them.")
+	rootCmd.Flags().String("state-file", "", "Path to the quarantine state file (default \"~/.schema-deletion-tool/quarantine.json\").")
+	rootCmd.Flags().String("isolation-level", pkg.ReadCommitted, `Isolation level used to read transactional messages, either "read_committed", the Kafka default, or "read_uncommitted". Reading uncommitted messages scans every topic twice in full, once more skipping aborted and open transactions, and cannot resume from a checkpoint.`)
+	rootCmd.Flags().String("recheck-policy", pkg.RecheckDrop, `What to do with selected schemas used since the scan, either "drop" or "abort".`)
+	rootCmd.Flags().Duration("watch", 0, "How long to watch the scanned topics for usages of soft deleted schemas before hard deleting them.")
+	rootCmd.Flags().Bool("auto-rollback", false, "Register again soft deleted schemas that are used while watching.")
+	rootCmd.Flags().Bool("scan-mirrors", false, "Scan every copy of mirrored topics, instead of only their source when it is scanned.")
+	rootCmd.Flags().Bool("consumer-group-policy", false, "Allow deleting schemas only used by messages that every consumer group has already consumed.")
+	rootCmd.Flags().Duration("stale-after", 0, "Also offer deleting schemas only used by messages older than this, e.g. 8760h. Stale schemas require extra confirmation.")
+	rootCmd.Flags().Duration("timeout", 0, "Maximum duration of listing and scanning the topics of each schema context, e.g. 2h. Prompts and deletions are not limited. No limit by default.")
+	rootCmd.Flags().Duration("topic-timeout", 0, "Maximum duration of scanning a single topic, e.g. 10m. Topics not scanned in time are treated as unscannable.")
+	rootCmd.Flags().String("progress-file", "", "Path to the file where scan progress is checkpointed (default \"~/.schema-deletion-tool/progress.json\").")
+	rootCmd.Flags().String("resume", "", "Path to the progress file of an interrupted or failed scan to continue from.")
 	rootCmd.Flags().String("config-file", "", "Path to config file containing credentials for Kafka clusters.")
+	rootCmd.MarkFlagsMutuallyExclusive("purge", "quarantine", "finalize")
+	// Quarantined schemas still used by old messages would never be finalized, as they show up as in use.
+	rootCmd.MarkFlagsMutuallyExclusive("stale-after", "quarantine")
+	rootCmd.MarkFlagsMutuallyExclusive("stale-after", "finalize")
+	rootCmd.MarkFlagsMutuallyExclusive("resume", "progress-file")
+	rootCmd.MarkFlagsMutuallyExclusive("discover-topics", "scan-all-topics")
+	rootCmd.MarkFlagsMutuallyExclusive("context", "all-contexts")
+	rootCmd.MarkFlagsMutuallyExclusive("resume", "all-contexts")
+	rootCmd.MarkFlagsMutuallyExclusive("environment", "all-environments")
+	rootCmd.MarkFlagsMutuallyExclusive("resume", "all-environments")
 
-	if err := rootCmd.Execute(); err != nil {
+	runCtx, cancel := pkg.HandleInterrupts(context.Background())
+	err := rootCmd.ExecuteContext(runCtx)
+	cancel()
+	if err != nil {
 		os.Exit(1)
 	}
 }
@@ -1,19 +1,27 @@
 package pkg
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"strconv"
 	"strings"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
 )
 
-func GetAllEligibleSubjects() ([]string, error) {
+// GetAllEligibleSubjects lists the subjects starting with the given prefix, e.g. the prefix of a schema context
+// or ALL_CONTEXTS_PREFIX, or the subjects of the default context if the prefix is empty.
+func GetAllEligibleSubjects(runCtx context.Context, ctx *Context, prefix string) ([]string, error) {
 	var subjects []string
-	output, err := ExecuteCommand(Confluent, []string{"schema-registry", "subject", "list", "-o", "json"}, false)
+	// Include soft deleted subjects so that their versions can still be purged.
+	args := []string{"schema-registry", "subject", "list", "--deleted", "-o", "json"}
+	if len(prefix) > 0 {
+		args = append(args, "--prefix", prefix)
+	}
+	output, err := ExecuteCommand(runCtx, Confluent, ctx.withEnvironment(args), false)
 	if err != nil {
-		return nil, errors.New(`error while listing Schema Registry subjects. Check you have proper credentials` +
-			` stored by running any Schema Registry command, e.g. "confluent schema-registry subject list"`)
+		return nil, credentialsError("listing Schema Registry subjects")
 	}
 	var _subjects []map[string]string
 	err = json.Unmarshal(output, &_subjects)
@@ -21,16 +29,16 @@ func GetAllEligibleSubjects() ([]string, error) {
 		return nil, err
 	}
 	for _, s := range _subjects {
-		if VerifySubject(s["subject"]) {
+		if VerifySubject(s["subject"]) || ctx.AnyNamingStrategy() {
 			subjects = append(subjects, s["subject"])
 		}
 	}
 	return subjects, nil
 }
 
-func ListClusters(ctx *Context) error {
+func ListClusters(runCtx context.Context, ctx *Context) error {
 	fmt.Println("Listing all clusters under the environment...")
-	output, err := ExecuteCommand(Confluent, []string{"kafka", "cluster", "list", "-o", "json"}, false)
+	output, err := ExecuteCommand(runCtx, Confluent, ctx.withEnvironment([]string{"kafka", "cluster", "list", "-o", "json"}), false)
 	if err != nil {
 		return err
 	}
@@ -62,126 +70,444 @@ func ListClusters(ctx *Context) error {
 	return ctx.SetClusters(clusterCandidates)
 }
 
-func ListAndScanTopics(ctx *Context) (map[int32]int, error) {
-	topicsWithClusterInfo, err := listTopics(ctx)
+func ListAndScanTopics(runCtx context.Context, ctx *Context) (map[int32]int, error) {
+	topicsWithClusterInfo, err := listTopics(runCtx, ctx)
 	if err != nil {
 		return nil, err
 	}
-	usedSchemas, err := scanTopics(topicsWithClusterInfo, ctx)
+	usedSchemas, err := scanTopics(runCtx, topicsWithClusterInfo, ctx)
 	if err != nil {
+		if runCtx.Err() != nil {
+			// Keep what has been scanned so far instead of losing it.
+			ReportPartialScan(ctx, len(topicsWithClusterInfo))
+			if saveErr := SaveProgress(ctx); saveErr != nil {
+				return nil, saveErr
+			}
+			return nil, fmt.Errorf("scan stopped: %v, progress saved to %s, run again with --resume %s to continue", err, ctx.ProgressFile, ctx.ProgressFile)
+		}
 		return nil, err
 	}
 
 	return usedSchemas, nil
 }
 
-func GetAllSchemas(ctx *Context) ([]SchemaInfo, error) {
+// ScanEnvironmentTopics scans every topic of the clusters once for all the schema contexts of the environment.
+func ScanEnvironmentTopics(runCtx context.Context, ctx *Context) error {
+	fmt.Println("Scanning every topic once for all the schema contexts...")
+	ctx.Scans = nil
+	if _, err := ListAndScanTopics(runCtx, ctx); err != nil {
+		return err
+	}
+	// Even an environment without any topic has been scanned.
+	ctx.TopicScans = append([]*TopicScan{}, ctx.Scans...)
+	ctx.Scans = nil
+	return nil
+}
+
+// ShareTopicScans adds the scans of the topics of the clusters of the schema context, taken from the scan of the
+// environment, to the context. It returns the schema IDs in use by those topics.
+func ShareTopicScans(ctx *Context) map[int32]int {
+	clusters, _ := ctx.topicsByCluster()
+	usedSchemas := make(map[int32]int)
+	for _, scan := range ctx.TopicScans {
+		if !ContainsTopic(scan.ClusterID, clusters) {
+			continue
+		}
+		// Rechecking the topic before deleting must not change the scan of the other schema contexts.
+		contextScan := *scan
+		ctx.Scans = append(ctx.Scans, &contextScan)
+		if scan.Status == SCANNED {
+			for k, v := range usageTypes(scan.Usages) {
+				usedSchemas[k] = usedSchemas[k] | v
+			}
+		}
+	}
+	return usedSchemas
+}
+
+func GetAllSchemas(runCtx context.Context, ctx *Context) ([]SchemaInfo, error) {
 	var schemas []SchemaInfo
+	// The active versions of every subject of the schema context are listed at once.
+	activeSchemas, err := listSchemas(runCtx, ctx, ContextPrefix(NormalizeContext(ctx.SchemaContext)), false)
+	if err != nil {
+		return nil, err
+	}
 	for _, subject := range ctx.Subjects {
-		var _schemas []SchemaInfo
 		fmt.Printf("Scanning schemas under subject %s...", subject)
-		output, err := ExecuteCommand(Confluent, []string{"schema-registry", "schema", "list", "--subject-prefix", subject, "-o", "json"}, false)
+		allSchemas, err := listSchemas(runCtx, ctx, subject, true)
 		if err != nil {
-			return nil, errors.New(`error while listing all schemas. Check you have proper credentials` +
-				` stored by running any Schema Registry command, e.g. "confluent schema-registry subject list"`)
-		}
-		if err = json.Unmarshal(output, &_schemas); err != nil {
 			return nil, err
 		}
+		_schemas := markSoftDeleted(filterSubject(allSchemas, subject), filterSubject(activeSchemas, subject))
 
-		fmt.Printf("  Found %d schema(s).\n", len(_schemas))
+		fmt.Printf("  Found %d schema(s), %d of which soft deleted.\n", len(_schemas), len(SoftDeletedSchemas(_schemas)))
 		schemas = append(schemas, _schemas...)
 	}
 
 	return schemas, nil
 }
 
-func listTopics(ctx *Context) ([]TopicWithClusterInfo, error) {
+// listSchemas lists the versions of the subjects starting with the given prefix, including the soft deleted
+// ones if told.
+func listSchemas(runCtx context.Context, ctx *Context, prefix string, includeDeleted bool) ([]SchemaInfo, error) {
+	var schemas []SchemaInfo
+	args := []string{"schema-registry", "schema", "list", "-o", "json"}
+	if len(prefix) > 0 {
+		args = append(args, "--subject-prefix", prefix)
+	}
+	if includeDeleted {
+		args = append(args, "--all")
+	}
+	output, err := ExecuteCommand(runCtx, Confluent, ctx.withEnvironment(args), false)
+	if err != nil {
+		return nil, credentialsError("listing all schemas")
+	}
+	if err = json.Unmarshal(output, &schemas); err != nil {
+		return nil, err
+	}
+	return schemas, nil
+}
+
+// filterSubject keeps the schemas of the given subject only, leaving out those of other subjects starting with
+// the same prefix, e.g. "orders-value-v2" when listing "orders-value".
+func filterSubject(schemas []SchemaInfo, subject string) []SchemaInfo {
+	var filtered []SchemaInfo
+	for _, schema := range schemas {
+		if schema.Subject == subject {
+			filtered = append(filtered, schema)
+		}
+	}
+	return filtered
+}
+
+// markSoftDeleted sets the state of every schema in allSchemas, which includes soft deleted versions, based on
+// whether it is also listed in activeSchemas.
+func markSoftDeleted(allSchemas, activeSchemas []SchemaInfo) []SchemaInfo {
+	active := make(map[string]map[json.Number]struct{})
+	for _, schema := range activeSchemas {
+		if _, ok := active[schema.Subject]; !ok {
+			active[schema.Subject] = make(map[json.Number]struct{})
+		}
+		active[schema.Subject][schema.Version] = struct{}{}
+	}
+	schemas := make([]SchemaInfo, len(allSchemas))
+	for i, schema := range allSchemas {
+		schema.State = SOFT_DELETED
+		if _, ok := active[schema.Subject][schema.Version]; ok {
+			schema.State = ACTIVE
+		}
+		schemas[i] = schema
+	}
+	return schemas
+}
+
+// SoftDeletedSchemas returns the schemas that have already been soft deleted, e.g. by a previous run.
+func SoftDeletedSchemas(schemas []SchemaInfo) []SchemaInfo {
+	var softDeleted []SchemaInfo
+	for _, schema := range schemas {
+		if schema.State == SOFT_DELETED {
+			softDeleted = append(softDeleted, schema)
+		}
+	}
+	return softDeleted
+}
+
+func listTopics(runCtx context.Context, ctx *Context) ([]TopicWithClusterInfo, error) {
 	fmt.Print("Scanning clusters for eligible topics...")
 	var topicsWithClusterInfo []TopicWithClusterInfo
-	for _, cluster := range ctx.Clusters {
-		output, err := ExecuteCommand(Confluent, []string{"kafka", "topic", "list", "--cluster", cluster, "-o", "json"}, false)
-		if err != nil {
-			return nil, err
+	clusters, clusterTopics := ctx.topicsByCluster()
+	for _, cluster := range clusters {
+		topics, err := listClusterTopics(runCtx, ctx, cluster)
+		if runCtx.Err() != nil {
+			return nil, runCtx.Err()
 		}
-		var topics []TopicName
-		if err = json.Unmarshal(output, &topics); err != nil {
-			return nil, err
+		if err != nil {
+			// Any of the eligible topics may exist in the cluster, so none of them can be considered fully scanned.
+			fmt.Printf("%sFailed to list topics of cluster %s: %v%s\n", RED, cluster, err, RESET)
+			if ctx.AnyNamingStrategy() {
+				ctx.Scans = append(ctx.Scans, &TopicScan{TopicWithClusterInfo: TopicWithClusterInfo{ALL_TOPICS, cluster},
+					Status: SCAN_FAILED, Error: err.Error()})
+			}
+			for _, topic := range clusterTopics[cluster].names {
+				ctx.Scans = append(ctx.Scans, &TopicScan{TopicWithClusterInfo: TopicWithClusterInfo{topic, cluster},
+					Status: SCAN_FAILED, Error: err.Error()})
+			}
+			continue
 		}
 		for _, topic := range topics {
-			if ContainsTopic(topic.Name, ctx.Topics) {
+			_, discovered := ctx.DiscoveredTopics[TopicWithClusterInfo{topic.Name, cluster}]
+			if clusterTopics[cluster].matches(topic.Name) || discovered || ctx.AnyNamingStrategy() && !isInternalTopic(topic.Name) {
 				topicsWithClusterInfo = append(topicsWithClusterInfo, TopicWithClusterInfo{topic.Name, cluster})
 			}
 		}
 	}
 
 	fmt.Printf("Found %d topic(s).\n", len(topicsWithClusterInfo))
-	PrintTable(TopicInfoFields, topicsWithClusterInfo, false)
+	selected, listedTopics := selectMirrors(topicsWithClusterInfo, listMirrorTopics(runCtx, ctx, topicsWithClusterInfo), ctx.ScanMirrors)
+	if runCtx.Err() != nil {
+		return nil, runCtx.Err()
+	}
+	for i := range listedTopics {
+		listedTopics[i].Note = discoveryNote(ctx, TopicWithClusterInfo{listedTopics[i].Topic, listedTopics[i].ClusterID})
+	}
+	PrintTable(ListedTopicFields, listedTopics, false)
+
+	return selected, nil
+}
 
-	return topicsWithClusterInfo, nil
+func listClusterTopics(runCtx context.Context, ctx *Context, cluster string) ([]TopicName, error) {
+	if bootstrapServers := ctx.Topology.cluster(cluster).BootstrapServers; len(bootstrapServers) > 0 {
+		return listBootstrapTopics(bootstrapServers, ctx.Credentials[cluster])
+	}
+	output, err := ExecuteCommand(runCtx, Confluent, ctx.withClusterEnvironment(cluster, []string{"kafka", "topic", "list", "--cluster", cluster, "-o", "json"}), false)
+	if err != nil {
+		return nil, err
+	}
+	var topics []TopicName
+	if err = json.Unmarshal(output, &topics); err != nil {
+		return nil, err
+	}
+	return topics, nil
 }
 
-func scanTopics(topics []TopicWithClusterInfo, ctx *Context) (map[int32]int, error) {
+// scanTopics scans the topics one by one. A topic that cannot be scanned is recorded as failed and does not
+// stop the others from being scanned.
+func scanTopics(runCtx context.Context, topics []TopicWithClusterInfo, ctx *Context) (map[int32]int, error) {
 	activeSchemas := make(map[int32]int)
 	for _, topic := range topics {
+		scan := &TopicScan{TopicWithClusterInfo: topic}
+		ctx.Scans = append(ctx.Scans, scan)
+		resumed := ctx.Resume.topic(topic)
+		if resumed != nil && resumed.Status == SCANNED {
+			fmt.Printf("Topic %s%s%s from cluster %s already scanned, skipping...\n", GREEN, topic.Topic, RESET, topic.ClusterID)
+			scan.Endpoint = resumed.Endpoint
+			scan.HighWatermarks = resumed.HighWatermarks
+			scan.Usages = resumed.Usages
+			scan.UncommittedOnly = resumed.UncommittedOnly
+			scan.Stats = resumed.Stats
+			scan.Status = SCANNED
+			for k, v := range usageTypes(scan.Usages) {
+				activeSchemas[k] = activeSchemas[k] | v
+			}
+			continue
+		}
 		fmt.Printf("Scanning topic %s%s%s from cluster %s...\n", GREEN, topic.Topic, RESET, topic.ClusterID)
-		output, err := ExecuteCommand(Confluent, []string{"kafka", "cluster", "describe", topic.ClusterID, "-o", "json"}, false)
-		var cluster map[string]interface{}
-		if err = json.Unmarshal(output, &cluster); err != nil {
-			return nil, err
+		topicCtx, cancel := runCtx, context.CancelFunc(func() {})
+		if ctx.TopicTimeout > 0 {
+			topicCtx, cancel = context.WithTimeout(runCtx, ctx.TopicTimeout)
 		}
-		consumer, err := CreateConsumer(cluster["endpoint"].(string), ctx.Credentials[topic.ClusterID])
+		err := scanTopic(topicCtx, scan, resumed, ctx)
+		cancel()
 		if err != nil {
-			return nil, err
+			if runCtx.Err() != nil {
+				scan.Status = SCAN_INTERRUPTED
+				return nil, runCtx.Err()
+			}
+			if topicCtx.Err() == context.DeadlineExceeded {
+				err = fmt.Errorf("scan did not complete within %s", ctx.TopicTimeout)
+			}
+			scan.Status = SCAN_FAILED
+			scan.Error = err.Error()
+			fmt.Printf("%sFailed to scan topic %s from cluster %s: %v%s\n", RED, topic.Topic, topic.ClusterID, err, RESET)
+			checkpoint(ctx)
+			continue
+		}
+		scan.Status = SCANNED
+		scan.Positions = nil
+		for k, v := range usageTypes(scan.Usages) {
+			activeSchemas[k] = activeSchemas[k] | v
 		}
+		checkpoint(ctx)
+	}
+	PrintTable(TopicScanFields, ctx.Scans, false)
+	return activeSchemas, nil
+}
+
+// scanTopic scans a topic up to its current high watermarks. If a previous scan of the topic got interrupted,
+// it continues from the positions that scan reached, up to the same high watermarks.
+func scanTopic(runCtx context.Context, scan *TopicScan, resumed *TopicProgress, ctx *Context) error {
+	endpoint, err := clusterEndpoint(runCtx, ctx, scan.ClusterID)
+	if err != nil {
+		return err
+	}
+	consumer, err := CreateConsumer(endpoint, ctx.Credentials[scan.ClusterID], ctx.IsolationLevel)
+	if err != nil {
+		return err
+	}
+	defer CloseConsumer(consumer)
 
-		_activeSchemas, err := scanActiveSchemas(consumer, topic.Topic)
+	lowWatermarks, highWatermarks, err := queryWatermarks(consumer, scan.Topic)
+	if err != nil {
+		return err
+	}
+	startOffsets := lowWatermarks
+	var previousUsages map[int32]*SchemaUsage
+	// Usages only found in uncommitted messages are told by scanning the whole topic twice, so a topic read
+	// uncommitted is always scanned again from the start.
+	if resumed != nil && len(resumed.Positions) > 0 && ctx.IsolationLevel == ReadCommitted {
+		fmt.Println("Resuming from the last checkpoint...")
+		startOffsets = make(map[int32]kafka.Offset)
+		for partition, position := range resumed.Positions {
+			// Messages deleted by retention since the checkpoint no longer need to be scanned.
+			if low, ok := lowWatermarks[partition]; ok && position < low {
+				position = low
+			}
+			startOffsets[partition] = position
+		}
+		highWatermarks = resumed.HighWatermarks
+		previousUsages = resumed.Usages
+		scan.Stats = resumed.Stats
+	}
+	scan.HighWatermarks = highWatermarks
+	onCheckpoint := func(positions map[int32]kafka.Offset, usages map[int32]*SchemaUsage) {
+		scan.Positions = positions
+		scan.Usages = mergeUsages(previousUsages, usages)
+		checkpoint(ctx)
+	}
+	if ctx.IsolationLevel == ReadUncommitted {
+		// The checkpointed usages would mix committed and uncommitted messages.
+		onCheckpoint = nil
+	}
+	usages, err := scanRange(runCtx, consumer, scan.Topic, startOffsets, highWatermarks, onCheckpoint, &scan.Stats)
+	if err != nil {
+		return err
+	}
+	usages = mergeUsages(previousUsages, usages)
+	if ctx.IsolationLevel == ReadUncommitted {
+		// Scan the same messages again, skipping aborted and still open transactions, to tell which usages only
+		// come from them.
+		fmt.Println("Scanning committed messages only...")
+		committedConsumer, err := CreateConsumer(endpoint, ctx.Credentials[scan.ClusterID], ReadCommitted)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		for k, v := range _activeSchemas {
-			activeSchemas[k] = activeSchemas[k] | v
+		defer CloseConsumer(committedConsumer)
+		committedUsages, err := scanRange(runCtx, committedConsumer, scan.Topic, lowWatermarks, highWatermarks, nil, nil)
+		if err != nil {
+			return err
 		}
+		scan.UncommittedOnly = uncommittedOnly(usageTypes(usages), usageTypes(committedUsages))
 	}
-	return activeSchemas, nil
+	scan.Endpoint = endpoint
+	scan.Usages = usages
+	return nil
 }
 
-func SelectDeletionCandidates(schemas []SchemaInfo, usedSchemas map[int32]int) ([]SchemaInfo, error) {
-	var candidates []SchemaInfo
-	for _, schema := range schemas {
-		var schemaId, _ = strconv.Atoi(string(schema.SchemaID))
-		if IsKeySchema(schema.Subject) {
-			if usedSchemas[int32(schemaId)]&KEYONLY == 0 {
-				candidates = append(candidates, schema)
+// ReportUncommittedUsage lists the schemas whose usages in a topic only come from aborted or uncommitted
+// transactional messages. They are still protected, but operators can decide whether those usages matter.
+func ReportUncommittedUsage(ctx *Context, schemas []SchemaInfo) {
+	var usages []UncommittedUsage
+	for _, scan := range ctx.ScannedTopics() {
+		for _, schema := range schemas {
+			if isSchemaUsed(schema, scan.UncommittedOnly) {
+				usages = append(usages, UncommittedUsage{schema.SchemaID, schema.Subject, schema.Version, scan.Topic, scan.ClusterID})
 			}
-		} else {
-			if usedSchemas[int32(schemaId)]&VALUEONLY == 0 {
-				candidates = append(candidates, schema)
+		}
+	}
+	if len(usages) == 0 {
+		fmt.Println("No schemas are used only by aborted or uncommitted messages.")
+		return
+	}
+	fmt.Printf("Following %d schema usages only come from aborted or uncommitted messages. The schemas are kept as "+
+		"in use.\n", len(usages))
+	PrintTable(UncommittedUsageFields, usages, false)
+}
+
+// UnknownUsageSubjects returns the subjects whose topics could not be fully scanned. Their usage is unknown,
+// so none of their schemas may be deleted.
+func UnknownUsageSubjects(ctx *Context) []string {
+	failedTopics := make(map[string]struct{})
+	// Subjects whose schema IDs were found in discovered topics that could not be scanned.
+	var discovered []string
+	var unsampled bool
+	for _, scan := range ctx.Scans {
+		if scan.Status == SCAN_FAILED {
+			failedTopics[scan.Topic] = struct{}{}
+			discovered = append(discovered, ctx.DiscoveredTopics[scan.TopicWithClusterInfo]...)
+			unsampled = unsampled || ctx.sampleFailed(scan.TopicWithClusterInfo)
+		}
+	}
+	// Any topic may use the schemas of any subject when all topics are scanned, whatever their names, and so may
+	// a topic that could not be sampled for discovery.
+	if len(failedTopics) > 0 && ctx.AnyNamingStrategy() || unsampled {
+		return ctx.Subjects
+	}
+	var subjects []string
+	for _, subject := range ctx.Subjects {
+		if ContainsTopic(subject, discovered) {
+			subjects = append(subjects, subject)
+			continue
+		}
+		topics := ctx.subjectTopics(subject)
+		for topic := range failedTopics {
+			if topics.matches(topic) {
+				subjects = append(subjects, subject)
+				break
 			}
 		}
 	}
+	return subjects
+}
+
+// ProtectUnknownUsage leaves out the schemas of subjects whose topics could not be fully scanned, listing them
+// as usage unknown.
+func ProtectUnknownUsage(ctx *Context, schemas []SchemaInfo) []SchemaInfo {
+	subjects := UnknownUsageSubjects(ctx)
+	if len(subjects) == 0 {
+		return schemas
+	}
+	var remaining, unknown []SchemaInfo
+	for _, schema := range schemas {
+		if ContainsTopic(schema.Subject, subjects) {
+			unknown = append(unknown, schema)
+		} else {
+			remaining = append(remaining, schema)
+		}
+	}
+	fmt.Printf("%sUsage unknown%s: following %d schemas are protected as their topics could not be fully scanned.\n",
+		RED, RESET, len(unknown))
+	PrintTable(SchemaInfoFields, unknown, false)
+	return remaining
+}
+
+// isSchemaUsed checks whether the schema ID shows up in the message keys (for key subjects) or values
+// (for value subjects) of the scanned topics. Subjects of other naming strategies may be used by either.
+func isSchemaUsed(schema SchemaInfo, usedSchemas map[int32]int) bool {
+	var schemaId, _ = strconv.Atoi(string(schema.SchemaID))
+	if IsKeySchema(schema.Subject) {
+		return usedSchemas[int32(schemaId)]&KEYONLY != 0
+	}
+	if IsValueSchema(schema.Subject) {
+		return usedSchemas[int32(schemaId)]&VALUEONLY != 0
+	}
+	return usedSchemas[int32(schemaId)] != 0
+}
+
+// SelectDeletionCandidates prompts for the unused schemas to delete, then for the stale ones. It returns the whole
+// selection along with the stale schemas in it.
+func SelectDeletionCandidates(ctx *Context, schemas []SchemaInfo, usedSchemas map[int32]int) ([]SchemaInfo, []SchemaInfo, error) {
+	var candidates []SchemaInfo
+	for _, schema := range schemas {
+		if !isSchemaUsed(schema, usedSchemas) {
+			candidates = append(candidates, schema)
+		}
+	}
 	fmt.Printf("Following %d schemas are unused schemas that qualify for deletion.\n", len(candidates))
-	PrintTable(SchemaInfoFields, candidates, true)
+	PrintTable(SchemaCandidateFields, describeCandidates(ctx, candidates), true)
 
 	var selection []SchemaInfo
 	for {
 		fmt.Print("Please select the schemas you want to delete by typing the numbers (1st column), separated by comma: ")
 		resp, err := ReadLine()
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		if resp == "all" {
 			selection = candidates
 		} else if len(resp) > 0 {
-			numbers := strings.Split(resp, ",")
-
-			selection = make([]SchemaInfo, 0)
-			for _, n := range numbers {
-				no, err := strconv.ParseInt(n, 10, 0)
-				if err != nil {
-					return nil, err
-				}
-				selection = append(selection, candidates[no])
+			selection, err = parseSelection(resp, candidates)
+			if err != nil {
+				return nil, nil, err
 			}
 		}
 		PrintTable(SchemaInfoFields, selection, true)
@@ -190,7 +516,7 @@ func SelectDeletionCandidates(schemas []SchemaInfo, usedSchemas map[int32]int) (
 			resp, err = ReadLine()
 			ResetColor()
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			if IsValidChoice(resp) {
 				break
@@ -201,37 +527,263 @@ func SelectDeletionCandidates(schemas []SchemaInfo, usedSchemas map[int32]int) (
 		}
 	}
 
+	// Schemas only used by old messages are offered separately, as deleting them breaks reading those messages.
+	stale, err := selectStaleCandidates(ctx, StaleSchemas(ctx, schemas, usedSchemas))
+	if err != nil {
+		return nil, nil, err
+	}
+	return append(selection, stale...), stale, nil
+}
+
+// parseSelection returns the candidates at the comma separated positions.
+func parseSelection(resp string, candidates []SchemaInfo) ([]SchemaInfo, error) {
+	selection := make([]SchemaInfo, 0)
+	for _, n := range strings.Split(resp, ",") {
+		no, err := strconv.ParseInt(strings.TrimSpace(n), 10, 0)
+		if err != nil {
+			return nil, err
+		}
+		if no < 0 || int(no) >= len(candidates) {
+			return nil, fmt.Errorf("no schema numbered %d", no)
+		}
+		selection = append(selection, candidates[no])
+	}
 	return selection, nil
 }
 
-func DeleteSchemas(schemas []SchemaInfo) error {
-	var err error
-	for _, schema := range schemas {
-		_, err = ExecuteCommand(Confluent, []string{"schema-registry", "schema", "delete", "--subject", schema.Subject, "--version", string(schema.Version), "--force"}, true)
+func DeleteSchemas(runCtx context.Context, ctx *Context, selection, stale, schemas []SchemaInfo) (DeletionOutcome, error) {
+	var outcome DeletionOutcome
+	subjects, versions := splitSubjectDeletions(selection, schemas)
+	if err := softDeleteSchemas(runCtx, ctx, subjects, versions, schemas); err != nil {
+		return outcome, err
+	}
+	outcome.SoftDeleted = newDeletions(selection, schemas)
+	// Only offer to hard delete the schemas that have not been used while watching the topics.
+	safe, err := WatchDeletedSchemas(runCtx, ctx, selection)
+	if err != nil {
+		return outcome, err
+	}
+	if len(safe) == 0 {
+		fmt.Println("No schemas left to hard delete.")
+		return outcome, nil
+	}
+	if len(safe) < len(selection) {
+		PrintTable(SchemaInfoFields, safe, false)
+	}
+	confirmed, err := confirmHardDeletion()
+	if err != nil {
+		return outcome, err
+	}
+	if confirmed {
+		if safe, err = confirmStaleHardDeletion(ctx, safe, stale); err != nil {
+			return outcome, err
+		}
+		// Messages may have been produced while waiting for confirmation.
+		if safe, err = RecheckTopics(runCtx, ctx, safe); err != nil {
+			return outcome, err
+		}
+		hardSubjects, hardVersions := splitSubjectDeletions(safe, schemas)
+		cleared, err := hardDeleteSchemas(runCtx, ctx, hardSubjects, hardVersions)
 		if err != nil {
+			return outcome, err
+		}
+		outcome.HardDeleted = newDeletions(safe, schemas)
+		outcome.SoftDeleted = newDeletions(withoutVersions(selection, safe), schemas)
+		fmt.Printf("Cleaned up a total of %d schemas: %d version(s) deleted, %d subject(s) removed, "+
+			"config and mode cleared for %d subject(s).\n", len(safe), len(hardVersions), len(hardSubjects), cleared)
+	} else {
+		outcome.Declined = true
+		fmt.Printf("Soft deleted a total of %d schemas: %d version(s) deleted, %d subject(s) removed.\n",
+			len(selection), len(versions), len(subjects))
+	}
+	return outcome, nil
+}
+
+// PurgeSchemas permanently deletes the selected schemas, all of which must already be soft deleted.
+func PurgeSchemas(runCtx context.Context, ctx *Context, selection, stale, schemas []SchemaInfo) (DeletionOutcome, error) {
+	var outcome DeletionOutcome
+	subjects, versions := splitSubjectDeletions(selection, schemas)
+	for _, subject := range subjects {
+		if hasActiveVersions(subject, schemas) {
+			return outcome, fmt.Errorf("subject %s still has active versions and cannot be purged", subject)
+		}
+	}
+	for _, schema := range versions {
+		if schema.State != SOFT_DELETED {
+			return outcome, fmt.Errorf("version %s of subject %s is not soft deleted and cannot be purged", schema.Version, schema.Subject)
+		}
+	}
+	confirmed, err := confirmHardDeletion()
+	if err != nil {
+		return outcome, err
+	}
+	if !confirmed {
+		outcome.Declined = true
+		return outcome, nil
+	}
+	if selection, err = confirmStaleHardDeletion(ctx, selection, stale); err != nil {
+		return outcome, err
+	}
+	// Messages may have been produced while waiting for confirmation.
+	if selection, err = RecheckTopics(runCtx, ctx, selection); err != nil {
+		return outcome, err
+	}
+	subjects, versions = splitSubjectDeletions(selection, schemas)
+	cleared, err := hardDeleteSchemas(runCtx, ctx, subjects, versions)
+	if err != nil {
+		return outcome, err
+	}
+	outcome.Purged = newDeletions(selection, schemas)
+	fmt.Printf("Purged a total of %d soft deleted schemas: %d version(s) deleted, %d subject(s) removed, "+
+		"config and mode cleared for %d subject(s).\n", len(selection), len(versions), len(subjects), cleared)
+	return outcome, nil
+}
+
+// withoutVersions returns the schemas that are not among the excluded ones.
+func withoutVersions(schemas, excluded []SchemaInfo) []SchemaInfo {
+	var remaining []SchemaInfo
+	for _, schema := range schemas {
+		if !containsVersion(excluded, schema.Subject, schema.Version) {
+			remaining = append(remaining, schema)
+		}
+	}
+	return remaining
+}
+
+// softDeleteSchemas soft deletes the given subjects and versions, skipping the ones already soft deleted.
+func softDeleteSchemas(runCtx context.Context, ctx *Context, subjects []string, versions, schemas []SchemaInfo) error {
+	for _, subject := range subjects {
+		if !hasActiveVersions(subject, schemas) {
+			continue
+		}
+		if err := deleteSubject(runCtx, ctx, subject, false); err != nil {
+			return err
+		}
+	}
+	for _, schema := range versions {
+		if schema.State == SOFT_DELETED {
+			continue
+		}
+		if err := deleteSchemaVersion(runCtx, ctx, schema, false); err != nil {
 			return err
 		}
 	}
+	return nil
+}
+
+// hardDeleteSchemas permanently deletes the given subjects and versions, and returns for how many of the deleted
+// subjects the subject-level config and mode have been cleared.
+func hardDeleteSchemas(runCtx context.Context, ctx *Context, subjects []string, versions []SchemaInfo) (int, error) {
+	var cleared int
+	for _, subject := range subjects {
+		if err := deleteSubject(runCtx, ctx, subject, true); err != nil {
+			return cleared, err
+		}
+		if err := clearSubjectSettings(ctx, subject); err != nil {
+			fmt.Printf("%sFailed to clear the config and mode of deleted subject %s: %v%s\n", RED, subject, err, RESET)
+			continue
+		}
+		cleared++
+	}
+	for _, schema := range versions {
+		if err := deleteSchemaVersion(runCtx, ctx, schema, true); err != nil {
+			return cleared, err
+		}
+	}
+	return cleared, nil
+}
+
+func confirmHardDeletion() (bool, error) {
 	var resp string
+	var err error
 	for {
 		fmt.Printf("Confirm %shard%s deletion of above schemas by typing Y/N, hard deleted schemas cannot be recovered: %s", RED, RESET, RED)
 		resp, err = ReadLine()
 		ResetColor()
 		if err != nil {
-			return err
+			return false, err
 		}
 		if IsValidChoice(resp) {
 			break
 		}
 	}
-	if IsYes(resp) {
-		for _, schema := range schemas {
-			_, err = ExecuteCommand(Confluent, []string{"schema-registry", "schema", "delete", "--subject", schema.Subject, "--version", string(schema.Version), "--permanent", "--force"}, true)
-			if err != nil {
-				return err
+	return IsYes(resp), nil
+}
+
+func hasActiveVersions(subject string, schemas []SchemaInfo) bool {
+	for _, schema := range schemas {
+		if schema.Subject == subject && schema.State == ACTIVE {
+			return true
+		}
+	}
+	return false
+}
+
+// splitSubjectDeletions separates the selected schemas into subjects whose every version is selected, which
+// are deleted as a whole, and the remaining versions, which are deleted one by one.
+func splitSubjectDeletions(selection, schemas []SchemaInfo) ([]string, []SchemaInfo) {
+	total := subjectVersionSet(schemas)
+	selected := subjectVersionSet(selection)
+
+	var subjects []string
+	var versions []SchemaInfo
+	for _, schema := range selection {
+		if len(selected[schema.Subject]) == len(total[schema.Subject]) {
+			if !ContainsTopic(schema.Subject, subjects) {
+				subjects = append(subjects, schema.Subject)
 			}
+			continue
 		}
-		fmt.Printf("Cleaned up a total of %d schemas.\n", len(schemas))
+		versions = append(versions, schema)
 	}
-	return nil
+	return subjects, versions
+}
+
+// subjectVersionSet returns the distinct versions of each subject, so that a version listed twice is not
+// counted twice.
+func subjectVersionSet(schemas []SchemaInfo) map[string]map[json.Number]struct{} {
+	versions := make(map[string]map[json.Number]struct{})
+	for _, schema := range schemas {
+		if _, ok := versions[schema.Subject]; !ok {
+			versions[schema.Subject] = make(map[json.Number]struct{})
+		}
+		versions[schema.Subject][schema.Version] = struct{}{}
+	}
+	return versions
+}
+
+// deleteSubject deletes all versions of a subject at once.
+func deleteSubject(runCtx context.Context, ctx *Context, subject string, permanent bool) error {
+	args := []string{"schema-registry", "schema", "delete", "--subject", subject, "--version", "all", "--force"}
+	if permanent {
+		args = append(args, "--permanent")
+	}
+	return executeDeletion(runCtx, ctx.withEnvironment(args))
+}
+
+// clearSubjectSettings deletes the subject-level compatibility config of a permanently deleted subject and resets
+// its mode, so that a subject registered again under the same name starts from the global settings. Deleting the
+// config fails when the subject has none, which is not an error. Like deletions, this is not cancelled midway.
+func clearSubjectSettings(ctx *Context, subject string) error {
+	_, _ = ExecuteCommand(context.Background(), Confluent, ctx.withEnvironment([]string{"schema-registry", "configuration", "delete", "--subject", subject, "--force"}), true)
+	_, err := ExecuteCommand(context.Background(), Confluent, ctx.withEnvironment([]string{"schema-registry", "subject", "update", subject, "--mode", "READWRITE"}), true)
+	return err
+}
+
+func deleteSchemaVersion(runCtx context.Context, ctx *Context, schema SchemaInfo, permanent bool) error {
+	args := []string{"schema-registry", "schema", "delete", "--subject", schema.Subject, "--version", string(schema.Version), "--force"}
+	if permanent {
+		args = append(args, "--permanent")
+	}
+	return executeDeletion(runCtx, ctx.withEnvironment(args))
+}
+
+// executeDeletion runs a deletion command unless the run has been cancelled. A command that has started is not
+// killed when the run is cancelled, so that no deletion is left halfway.
+func executeDeletion(runCtx context.Context, args []string) error {
+	if runCtx.Err() != nil {
+		return runCtx.Err()
+	}
+	_, err := ExecuteCommand(context.Background(), Confluent, args, true)
+	return err
 }
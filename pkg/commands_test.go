@@ -0,0 +1,166 @@
+package pkg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitSubjectDeletions(t *testing.T) {
+	req := require.New(t)
+	schemas := []SchemaInfo{
+		{SchemaID: "100001", Subject: "orders-value", Version: "1"},
+		{SchemaID: "100002", Subject: "orders-value", Version: "2"},
+		{SchemaID: "100003", Subject: "payments-value", Version: "1"},
+		{SchemaID: "100004", Subject: "payments-value", Version: "2"},
+		{SchemaID: "100005", Subject: "users-key", Version: "1"},
+	}
+	selection := []SchemaInfo{
+		{SchemaID: "100001", Subject: "orders-value", Version: "1"},
+		{SchemaID: "100002", Subject: "orders-value", Version: "2"},
+		{SchemaID: "100003", Subject: "payments-value", Version: "1"},
+		{SchemaID: "100005", Subject: "users-key", Version: "1"},
+	}
+	subjects, versions := splitSubjectDeletions(selection, schemas)
+	req.Equal([]string{"orders-value", "users-key"}, subjects)
+	req.Equal([]SchemaInfo{{SchemaID: "100003", Subject: "payments-value", Version: "1"}}, versions)
+
+	subjects, versions = splitSubjectDeletions(nil, schemas)
+	req.Empty(subjects)
+	req.Empty(versions)
+
+	// A version listed twice does not keep its subject from being deleted as a whole.
+	subjects, versions = splitSubjectDeletions(selection, append(schemas, selection[0]))
+	req.Equal([]string{"orders-value", "users-key"}, subjects)
+	req.Len(versions, 1)
+}
+
+func TestIsSchemaUsed(t *testing.T) {
+	req := require.New(t)
+	usedSchemas := map[int32]int{100001: KEYONLY, 100002: VALUEONLY, 100003: KEYVALUE}
+	schemas := []SchemaInfo{
+		{SchemaID: "100001", Subject: "orders-key", Version: "1"},
+		{SchemaID: "100001", Subject: "orders-value", Version: "1"},
+		{SchemaID: "100002", Subject: "orders-value", Version: "2"},
+		{SchemaID: "100003", Subject: "payments-value", Version: "1"},
+		{SchemaID: "100003", Subject: "users-value", Version: "1"},
+		{SchemaID: "100004", Subject: "users-value", Version: "2"},
+	}
+	req.True(isSchemaUsed(schemas[0], usedSchemas))
+	req.False(isSchemaUsed(schemas[1], usedSchemas))
+	req.True(isSchemaUsed(schemas[2], usedSchemas))
+	req.False(isSchemaUsed(schemas[5], usedSchemas))
+	req.Equal("100001,100002,100003", usedSchemaIDs(schemas, usedSchemas))
+	req.Equal("", usedSchemaIDs(schemas[5:], usedSchemas))
+}
+
+func TestProtectUnknownUsage(t *testing.T) {
+	req := require.New(t)
+	ctx := &Context{
+		Subjects: []string{"orders-key", "orders-value", "payments-value"},
+		Scans: []*TopicScan{
+			{TopicWithClusterInfo: TopicWithClusterInfo{"orders", "lkc-123"}, Status: SCANNED},
+			{TopicWithClusterInfo: TopicWithClusterInfo{"orders", "lkc-456"}, Status: SCAN_FAILED},
+			{TopicWithClusterInfo: TopicWithClusterInfo{"payments", "lkc-123"}, Status: SCANNED},
+		},
+	}
+	req.Equal([]string{"orders-key", "orders-value"}, UnknownUsageSubjects(ctx))
+	req.Len(ctx.ScannedTopics(), 2)
+
+	schemas := []SchemaInfo{
+		{SchemaID: "100001", Subject: "orders-key", Version: "1"},
+		{SchemaID: "100002", Subject: "orders-value", Version: "1"},
+		{SchemaID: "100003", Subject: "payments-value", Version: "1"},
+	}
+	req.Equal(schemas[2:], ProtectUnknownUsage(ctx, schemas))
+
+	ctx.Scans[1].Status = SCANNED
+	req.Empty(UnknownUsageSubjects(ctx))
+	req.Equal(schemas, ProtectUnknownUsage(ctx, schemas))
+}
+
+func TestMarkSoftDeleted(t *testing.T) {
+	req := require.New(t)
+	allSchemas := []SchemaInfo{
+		{SchemaID: "100001", Subject: "orders-value", Version: "1"},
+		{SchemaID: "100002", Subject: "orders-value", Version: "2"},
+		{SchemaID: "100003", Subject: "orders-value", Version: "3"},
+	}
+	activeSchemas := []SchemaInfo{
+		{SchemaID: "100003", Subject: "orders-value", Version: "3"},
+	}
+	schemas := markSoftDeleted(allSchemas, activeSchemas)
+	req.Equal(SOFT_DELETED, schemas[0].State)
+	req.Equal(SOFT_DELETED, schemas[1].State)
+	req.Equal(ACTIVE, schemas[2].State)
+	req.Equal(schemas[:2], SoftDeletedSchemas(schemas))
+	req.True(hasActiveVersions("orders-value", schemas))
+	req.False(hasActiveVersions("orders-value", schemas[:2]))
+
+	// Every version of a soft deleted subject is soft deleted.
+	schemas = markSoftDeleted(allSchemas, nil)
+	req.Equal(schemas, SoftDeletedSchemas(schemas))
+}
+
+func TestFilterSubject(t *testing.T) {
+	req := require.New(t)
+	listed := []SchemaInfo{
+		{SchemaID: "100001", Subject: "orders-value", Version: "1"},
+		{SchemaID: "100002", Subject: "orders-value-v2", Version: "1"},
+		{SchemaID: "100003", Subject: "orders-value", Version: "2"},
+		{SchemaID: "100004", Subject: "orders-value-archive-value", Version: "1"},
+	}
+	req.Equal([]SchemaInfo{listed[0], listed[2]}, filterSubject(listed, "orders-value"))
+	req.Equal([]SchemaInfo{listed[1]}, filterSubject(listed, "orders-value-v2"))
+	req.Empty(filterSubject(listed, "orders-key"))
+
+	// Versions of overlapping subjects are neither attributed to the requested subject nor counted as active.
+	schemas := markSoftDeleted(filterSubject(listed, "orders-value"), filterSubject(listed[:2], "orders-value"))
+	req.Equal([]SchemaInfo{
+		{SchemaID: "100001", Subject: "orders-value", Version: "1", State: ACTIVE},
+		{SchemaID: "100003", Subject: "orders-value", Version: "2", State: SOFT_DELETED},
+	}, schemas)
+}
+
+func TestHardDeleteSchemasClearsSubjectSettings(t *testing.T) {
+	req := require.New(t)
+	ctx := &Context{Environment: "env-123"}
+	// Deleting the config of a subject without subject-level config fails.
+	commands := fakeConfluent(t, `case "$*" in *"configuration delete"*) exit 1;; esac`)
+	versions := []SchemaInfo{{SchemaID: "100002", Subject: "payments-value", Version: "1"}}
+	cleared, err := hardDeleteSchemas(context.Background(), ctx, []string{"orders-value"}, versions)
+	req.NoError(err)
+	req.Equal(1, cleared)
+	req.Equal([]string{
+		"schema-registry schema delete --subject orders-value --version all --force --permanent --environment env-123",
+		"schema-registry configuration delete --subject orders-value --force --environment env-123",
+		"schema-registry subject update orders-value --mode READWRITE --environment env-123",
+		"schema-registry schema delete --subject payments-value --version 1 --force --permanent --environment env-123",
+	}, commands())
+
+	// The subject is deleted all the same when its settings cannot be cleared.
+	fakeConfluent(t, `case "$*" in *"subject update"*) exit 1;; esac`)
+	cleared, err = hardDeleteSchemas(context.Background(), ctx, []string{"orders-value"}, nil)
+	req.NoError(err)
+	req.Zero(cleared)
+}
+
+func TestGetAllSchemasOverlappingSubjects(t *testing.T) {
+	req := require.New(t)
+	commands := fakeConfluent(t, `case "$*" in
+*--all*) echo '[{"id":1,"subject":"orders-value","version":1},{"id":2,"subject":"orders-value","version":2},{"id":3,"subject":"orders-value-v2","version":1}]';;
+*) echo '[{"id":2,"subject":"orders-value","version":2},{"id":3,"subject":"orders-value-v2","version":1}]';;
+esac`)
+	ctx := &Context{Environment: "env-123", SchemaContext: DEFAULT_CONTEXT, Subjects: []string{"orders-value"}}
+	schemas, err := GetAllSchemas(context.Background(), ctx)
+	req.NoError(err)
+	req.Equal([]SchemaInfo{
+		{SchemaID: "1", Subject: "orders-value", Version: "1", State: SOFT_DELETED},
+		{SchemaID: "2", Subject: "orders-value", Version: "2", State: ACTIVE},
+	}, schemas)
+	req.Equal([]string{
+		"schema-registry schema list -o json --environment env-123",
+		"schema-registry schema list -o json --subject-prefix orders-value --all --environment env-123",
+	}, commands())
+}
@@ -1,25 +1,74 @@
 package pkg
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 )
 
-func CreateConsumer(bootstrapServer string, credentials Credentials) (*kafka.Consumer, error) {
+// ScanIdleTimeout is how long a scan waits for messages or partition EOF events before giving up.
+const ScanIdleTimeout = 30 * time.Second
+
+var (
+	// runGroupID is only set because the client requires a group; it is never joined nor committed to.
+	runGroupID = fmt.Sprintf("schema-deletion-tool-%d-%d", os.Getpid(), time.Now().UnixNano())
+
+	openConsumers int32
+)
+
+// HandleInterrupts returns a context cancelled on Ctrl-C, so that scans stop gracefully and close their
+// consumers, and no further schemas are deleted. A second Ctrl-C exits right away.
+func HandleInterrupts(parent context.Context) (context.Context, context.CancelFunc) {
+	runCtx, cancel := context.WithCancel(parent)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		for range signals {
+			if runCtx.Err() != nil {
+				ResetColor()
+				fmt.Println()
+				os.Exit(130)
+			}
+			fmt.Printf("\n%sInterrupted, stopping... Press Ctrl-C again to exit right away.%s\n", RED, RESET)
+			cancel()
+		}
+	}()
+	return runCtx, cancel
+}
+
+// CreateConsumer creates a consumer for manual partition assignment only, which must be closed with CloseConsumer.
+func CreateConsumer(bootstrapServer string, credentials Credentials, isolationLevel string) (*kafka.Consumer, error) {
 	ccfg, err := createConsumerConfig(bootstrapServer, credentials)
 	if err != nil {
 		return nil, err
 	}
+	if err = ccfg.SetKey("isolation.level", isolationLevel); err != nil {
+		return nil, err
+	}
+	return newConsumer(ccfg)
+}
+
+func newConsumer(ccfg *kafka.ConfigMap) (*kafka.Consumer, error) {
 	consumer, err := kafka.NewConsumer(ccfg)
 	if err != nil {
 		return nil, err
 	}
+	atomic.AddInt32(&openConsumers, 1)
 	return consumer, nil
 }
 
+func CloseConsumer(consumer *kafka.Consumer) {
+	_ = consumer.Close()
+	atomic.AddInt32(&openConsumers, -1)
+}
+
 func createConsumerConfig(bootstrapServer string, credentials Credentials) (*kafka.ConfigMap, error) {
 	ccfg := &kafka.ConfigMap{}
 	if err := ccfg.SetKey("bootstrap.servers", bootstrapServer); err != nil {
@@ -40,71 +89,330 @@ func createConsumerConfig(bootstrapServer string, credentials Credentials) (*kaf
 	if err := ccfg.SetKey("sasl.password", credentials.ApiSecret); err != nil {
 		return nil, err
 	}
-	if err := ccfg.SetKey("group.id", "console-schema-deletion-tool"); err != nil {
+	if err := ccfg.SetKey("group.id", runGroupID); err != nil {
+		return nil, err
+	}
+	if err := ccfg.SetKey("enable.auto.commit", false); err != nil {
+		return nil, err
+	}
+	if err := ccfg.SetKey("enable.auto.offset.store", false); err != nil {
+		return nil, err
+	}
+	if err := ccfg.SetKey("enable.partition.eof", true); err != nil {
 		return nil, err
 	}
 	return ccfg, nil
 }
 
-func scanActiveSchemas(consumer *kafka.Consumer, topic string) (map[int32]int, error) {
-	activeSchemas := make(map[int32]int)
-	metadata, err := consumer.GetMetadata(&topic, false, 5000)
+// fetchCommittedOffsets returns the offsets committed by a consumer group for the given partitions of each topic,
+// leaving out partitions without committed offsets. The group is not joined, so its members are not affected.
+func fetchCommittedOffsets(bootstrapServer string, credentials Credentials, group string, partitions map[string]map[int32]kafka.Offset) (map[string]map[int32]kafka.Offset, error) {
+	ccfg, err := createConsumerConfig(bootstrapServer, credentials)
 	if err != nil {
 		return nil, err
 	}
-	DesiredNumPartitions := int32(len(metadata.Topics[topic].Partitions))
+	if err = ccfg.SetKey("group.id", group); err != nil {
+		return nil, err
+	}
+	consumer, err := newConsumer(ccfg)
+	if err != nil {
+		return nil, err
+	}
+	defer CloseConsumer(consumer)
+
 	var tpl []kafka.TopicPartition
-	var topicName = topic
-	for i := int32(0); i < DesiredNumPartitions; i++ {
-		tpl = append(tpl, kafka.TopicPartition{
-			Topic:     &topicName,
-			Partition: i,
-			Offset:    kafka.OffsetBeginning,
-		})
+	for topic, topicPartitions := range partitions {
+		topicName := topic
+		for partition := range topicPartitions {
+			tpl = append(tpl, kafka.TopicPartition{Topic: &topicName, Partition: partition})
+		}
 	}
-	err = consumer.Assign(tpl)
+	committed, err := consumer.Committed(tpl, 5000)
 	if err != nil {
 		return nil, err
 	}
+	offsets := make(map[string]map[int32]kafka.Offset)
+	for _, tp := range committed {
+		if tp.Offset < 0 {
+			continue
+		}
+		if offsets[*tp.Topic] == nil {
+			offsets[*tp.Topic] = make(map[int32]kafka.Offset)
+		}
+		offsets[*tp.Topic][tp.Partition] = tp.Offset
+	}
+	return offsets, nil
+}
 
-	var totalMsg int64 = 0
-	endOffsets := make(map[int32]kafka.Offset)
-	offsets := make(map[int32]kafka.Offset)
-	for i := int32(0); i < DesiredNumPartitions; i++ {
-		low, high, err := consumer.QueryWatermarkOffsets(topic, i, -1)
+// scanActiveSchemas scans a topic from the beginning and returns how each schema ID is used, along with the
+// per-partition high watermarks the scan went up to.
+func scanActiveSchemas(runCtx context.Context, consumer *kafka.Consumer, topic string) (map[int32]*SchemaUsage, map[int32]kafka.Offset, error) {
+	startOffsets, highWatermarks, err := queryWatermarks(consumer, topic)
+	if err != nil {
+		return nil, nil, err
+	}
+	usages, err := scanRange(runCtx, consumer, topic, startOffsets, highWatermarks, nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return usages, highWatermarks, nil
+}
+
+// queryWatermarks returns the low and high watermarks of all partitions of a topic.
+func queryWatermarks(consumer *kafka.Consumer, topic string) (map[int32]kafka.Offset, map[int32]kafka.Offset, error) {
+	partitions, err := getPartitions(consumer, topic)
+	if err != nil {
+		return nil, nil, err
+	}
+	lowWatermarks := make(map[int32]kafka.Offset)
+	highWatermarks := make(map[int32]kafka.Offset)
+	for _, partition := range partitions {
+		low, high, err := consumer.QueryWatermarkOffsets(topic, partition, 5000)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		endOffsets[i] = kafka.Offset(high) - 1
-		offsets[i] = kafka.Offset(low) - 1
-		totalMsg += high - low
+		lowWatermarks[partition] = kafka.Offset(low)
+		highWatermarks[partition] = kafka.Offset(high)
 	}
+	return lowWatermarks, highWatermarks, nil
+}
 
+func scanRange(runCtx context.Context, consumer *kafka.Consumer, topic string, startOffsets, highWatermarks map[int32]kafka.Offset, onCheckpoint checkpointFunc, stats *MessageStats) (map[int32]*SchemaUsage, error) {
+	usages, totalMsg, err := consumeRange(runCtx, consumer, topic, startOffsets, highWatermarks, onCheckpoint, stats)
+	if err != nil {
+		return nil, err
+	}
 	if totalMsg == 0 {
 		fmt.Println("No messages found, skipping...")
-		return activeSchemas, nil
 	}
+	return usages, nil
+}
+
+// sampleRecentSchemas returns the schema IDs used by the most recent messages of each partition of a topic.
+func sampleRecentSchemas(runCtx context.Context, consumer *kafka.Consumer, topic string, samples int64) (map[int32]int, error) {
+	lowWatermarks, highWatermarks, err := queryWatermarks(consumer, topic)
+	if err != nil {
+		return nil, err
+	}
+	startOffsets := make(map[int32]kafka.Offset)
+	for partition, high := range highWatermarks {
+		start := high - kafka.Offset(samples)
+		if start < lowWatermarks[partition] {
+			start = lowWatermarks[partition]
+		}
+		startOffsets[partition] = start
+	}
+	usages, _, err := consumeRange(runCtx, consumer, topic, startOffsets, highWatermarks, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return usageTypes(usages), nil
+}
 
-	for !checkIfReachesOffsets(endOffsets, offsets, DesiredNumPartitions) {
-		msg, err := consumer.ReadMessage(5 * time.Second)
+// scanNewMessages scans only the messages produced to a topic after the given high watermarks, and returns the
+// schema IDs in use, the number of new messages and the current high watermarks.
+func scanNewMessages(runCtx context.Context, consumer *kafka.Consumer, topic string, highWatermarks map[int32]kafka.Offset) (map[int32]int, int64, map[int32]kafka.Offset, error) {
+	partitions, err := getPartitions(consumer, topic)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	startOffsets := make(map[int32]kafka.Offset)
+	currentWatermarks := make(map[int32]kafka.Offset)
+	for _, partition := range partitions {
+		low, high, err := consumer.QueryWatermarkOffsets(topic, partition, 5000)
 		if err != nil {
-			return nil, err
+			return nil, 0, nil, err
+		}
+		// Partitions added since the scan, or whose recorded offsets have been deleted by retention, are
+		// scanned from the earliest available offset.
+		start, ok := highWatermarks[partition]
+		if !ok || start < kafka.Offset(low) {
+			start = kafka.Offset(low)
+		}
+		startOffsets[partition] = start
+		currentWatermarks[partition] = kafka.Offset(high)
+	}
+
+	usages, totalMsg, err := consumeRange(runCtx, consumer, topic, startOffsets, currentWatermarks, nil, nil)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	return usageTypes(usages), totalMsg, currentWatermarks, nil
+}
+
+// nextPositions returns the offsets to continue consuming from, given the last consumed offset of each partition.
+func nextPositions(offsets map[int32]kafka.Offset) map[int32]kafka.Offset {
+	positions := make(map[int32]kafka.Offset)
+	for partition, offset := range offsets {
+		positions[partition] = offset + 1
+	}
+	return positions
+}
+
+func getPartitions(consumer *kafka.Consumer, topic string) ([]int32, error) {
+	metadata, err := consumer.GetMetadata(&topic, false, 5000)
+	if err != nil {
+		return nil, err
+	}
+	var partitions []int32
+	for _, partition := range metadata.Topics[topic].Partitions {
+		partitions = append(partitions, partition.ID)
+	}
+	return partitions, nil
+}
+
+// checkpointFunc is called with the per-partition offsets to continue from and the usages found so far.
+type checkpointFunc func(positions map[int32]kafka.Offset, usages map[int32]*SchemaUsage)
+
+// consumeRange consumes each partition from its start offset up to its end offset (exclusive), calling
+// onCheckpoint, if any, every CheckpointInterval and when cancelled.
+func consumeRange(runCtx context.Context, consumer *kafka.Consumer, topic string, startOffsets, endOffsets map[int32]kafka.Offset, onCheckpoint checkpointFunc, stats *MessageStats) (map[int32]*SchemaUsage, int64, error) {
+	usages := make(map[int32]*SchemaUsage)
+	var tpl []kafka.TopicPartition
+	var topicName = topic
+	var totalMsg int64 = 0
+	lastOffsets := make(map[int32]kafka.Offset)
+	offsets := make(map[int32]kafka.Offset)
+	var partitions int32 = 0
+	for partition, start := range startOffsets {
+		// A partition resumed past its end has nothing left to scan.
+		if start > endOffsets[partition] {
+			start = endOffsets[partition]
 		}
-		offsets[msg.TopicPartition.Partition] = msg.TopicPartition.Offset
+		tpl = append(tpl, kafka.TopicPartition{
+			Topic:     &topicName,
+			Partition: partition,
+			Offset:    start,
+		})
+		lastOffsets[partition] = endOffsets[partition] - 1
+		offsets[partition] = start - 1
+		totalMsg += int64(endOffsets[partition] - start)
+		if partition >= partitions {
+			partitions = partition + 1
+		}
+	}
 
-		value := msg.Value
-		if len(value) >= MessageOffset && value[0] == MagicByte {
-			schemaID := int32(binary.BigEndian.Uint32(value[1:MessageOffset]))
-			activeSchemas[schemaID] = activeSchemas[schemaID] | VALUEONLY
+	if totalMsg == 0 {
+		return usages, 0, nil
+	}
+	if runCtx.Err() != nil {
+		return nil, 0, runCtx.Err()
+	}
+	err := consumer.Assign(tpl)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// The last record of a partition may never be delivered, e.g. a transaction marker or a compacted record, so
+	// a partition EOF event also tells its end is reached.
+	lastProgress := time.Now()
+	lastCheckpoint := time.Now()
+	for !checkIfReachesOffsets(lastOffsets, offsets, partitions) {
+		if runCtx.Err() != nil {
+			if onCheckpoint != nil {
+				onCheckpoint(nextPositions(offsets), usages)
+			}
+			return nil, 0, runCtx.Err()
+		}
+		if onCheckpoint != nil && time.Since(lastCheckpoint) > CheckpointInterval {
+			onCheckpoint(nextPositions(offsets), usages)
+			lastCheckpoint = time.Now()
 		}
-		key := msg.Key
-		if len(key) >= MessageOffset && key[0] == MagicByte {
-			schemaID := int32(binary.BigEndian.Uint32(key[1:MessageOffset]))
-			activeSchemas[schemaID] = activeSchemas[schemaID] | KEYONLY
+		switch e := consumer.Poll(100).(type) {
+		case *kafka.Message:
+			lastProgress = time.Now()
+			partition := e.TopicPartition.Partition
+			if e.TopicPartition.Offset > lastOffsets[partition] {
+				// Positions are never checkpointed past the end of the range.
+				offsets[partition] = lastOffsets[partition]
+				continue
+			}
+			if e.TopicPartition.Offset > offsets[partition] {
+				offsets[partition] = e.TopicPartition.Offset
+			}
+			if stats != nil {
+				stats.record(e)
+			}
+			for schemaID, usageType := range decodeSchemaIDs(e) {
+				if _, ok := usages[schemaID]; !ok {
+					usages[schemaID] = newSchemaUsage()
+				}
+				usages[schemaID].record(e, usageType)
+			}
+		case kafka.PartitionEOF:
+			lastProgress = time.Now()
+			// Nothing is left to read from the partition, up to the high watermark or, when reading committed
+			// messages only, up to the last stable offset.
+			if _, ok := lastOffsets[e.Partition]; ok && offsets[e.Partition] < lastOffsets[e.Partition] {
+				offsets[e.Partition] = lastOffsets[e.Partition]
+			}
+		case kafka.Error:
+			if isScanError(e) {
+				return nil, 0, e
+			}
+		case nil:
+			if time.Since(lastProgress) > ScanIdleTimeout {
+				return nil, 0, fmt.Errorf("no progress scanning topic %s for %s", topic, ScanIdleTimeout)
+			}
 		}
 	}
 
-	return activeSchemas, nil
+	return usages, totalMsg, nil
+}
+
+// isScanError tells whether an error reported by the consumer prevents the scan from completing, as opposed to
+// transient errors that the client recovers from by itself.
+func isScanError(err kafka.Error) bool {
+	// Group authorization failures are not among them, as consumers don't need access to their group.
+	switch err.Code() {
+	case kafka.ErrTopicAuthorizationFailed, kafka.ErrClusterAuthorizationFailed,
+		kafka.ErrUnknownTopicOrPart, kafka.ErrUnknownTopic, kafka.ErrUnknownPartition, kafka.ErrAuthentication:
+		return true
+	}
+	return err.IsFatal()
+}
+
+// decodeSchemaIDs returns the schema IDs in the key and value of a message serialized with the
+// Confluent wire format, along with whether they are used by the key, the value or both.
+func decodeSchemaIDs(msg *kafka.Message) map[int32]int {
+	schemaIDs := make(map[int32]int)
+	value := msg.Value
+	if isFramed(value) {
+		schemaID := int32(binary.BigEndian.Uint32(value[1:MessageOffset]))
+		schemaIDs[schemaID] = schemaIDs[schemaID] | VALUEONLY
+	}
+	key := msg.Key
+	if isFramed(key) {
+		schemaID := int32(binary.BigEndian.Uint32(key[1:MessageOffset]))
+		schemaIDs[schemaID] = schemaIDs[schemaID] | KEYONLY
+	}
+	return schemaIDs
+}
+
+// isFramed tells whether the data starts with the magic byte and a schema ID, as in the Confluent wire format.
+func isFramed(data []byte) bool {
+	return len(data) >= MessageOffset && data[0] == MagicByte
+}
+
+// usageTypes collapses the usages of schema IDs into whether they are used by message keys, values or both.
+func usageTypes(usages map[int32]*SchemaUsage) map[int32]int {
+	activeSchemas := make(map[int32]int)
+	for schemaID, usage := range usages {
+		activeSchemas[schemaID] = activeSchemas[schemaID] | usage.Type
+	}
+	return activeSchemas
+}
+
+// uncommittedOnly returns the usages found when reading uncommitted messages that are not found when reading
+// committed messages only, i.e. the usages coming from aborted or still open transactions.
+func uncommittedOnly(uncommittedSchemas, committedSchemas map[int32]int) map[int32]int {
+	schemas := make(map[int32]int)
+	for schemaID, usageType := range uncommittedSchemas {
+		if onlyType := usageType &^ committedSchemas[schemaID]; onlyType != 0 {
+			schemas[schemaID] = onlyType
+		}
+	}
+	return schemas
 }
 
 func checkIfReachesOffsets(endOffsets, offsets map[int32]kafka.Offset, partitions int32) bool {
@@ -1,7 +1,12 @@
 package pkg
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 	"github.com/stretchr/testify/require"
@@ -9,9 +14,11 @@ import (
 
 func TestCreateConsumer(t *testing.T) {
 	req := require.New(t)
-	consumer, err := CreateConsumer("", Credentials{"key", "value"})
+	requireConsumersClosed(t)
+	consumer, err := CreateConsumer("", Credentials{"key", "value"}, ReadCommitted)
 	req.NoError(err)
 	req.NotNil(consumer)
+	defer CloseConsumer(consumer)
 
 	topic := "my-topic"
 	err = consumer.Assign([]kafka.TopicPartition{
@@ -42,6 +49,22 @@ func TestCreateConsumer(t *testing.T) {
 	}
 }
 
+func TestCreateConsumerConfig(t *testing.T) {
+	req := require.New(t)
+	ccfg, err := createConsumerConfig("localhost:9092", Credentials{"key", "value"})
+	req.NoError(err)
+	groupID, err := ccfg.Get("group.id", "")
+	req.NoError(err)
+	req.Equal(runGroupID, groupID)
+	req.Contains(runGroupID, "schema-deletion-tool-")
+	autoCommit, err := ccfg.Get("enable.auto.commit", true)
+	req.NoError(err)
+	req.Equal(false, autoCommit)
+	offsetStore, err := ccfg.Get("enable.auto.offset.store", true)
+	req.NoError(err)
+	req.Equal(false, offsetStore)
+}
+
 func TestCompareOffsets(t *testing.T) {
 	req := require.New(t)
 	req.True(checkIfReachesOffsets(map[int32]kafka.Offset{0: kafka.OffsetEnd}, map[int32]kafka.Offset{}, 1))
@@ -52,3 +75,266 @@ func TestCompareOffsets(t *testing.T) {
 	req.False(checkIfReachesOffsets(map[int32]kafka.Offset{0: 2, 1: 4}, map[int32]kafka.Offset{0: 1, 1: 4}, 2))
 	req.False(checkIfReachesOffsets(map[int32]kafka.Offset{0: 2, 1: 4}, map[int32]kafka.Offset{0: 1, 1: 4}, 2))
 }
+
+func TestDecodeSchemaIDs(t *testing.T) {
+	req := require.New(t)
+	req.Equal(map[int32]int{100001: KEYONLY, 100002: VALUEONLY}, decodeSchemaIDs(&kafka.Message{
+		Key:   []byte{0, 0, 1, 134, 161, 'k'},
+		Value: []byte{0, 0, 1, 134, 162, 'v'},
+	}))
+	req.Equal(map[int32]int{100001: KEYVALUE}, decodeSchemaIDs(&kafka.Message{
+		Key:   []byte{0, 0, 1, 134, 161},
+		Value: []byte{0, 0, 1, 134, 161, 'v'},
+	}))
+	req.Empty(decodeSchemaIDs(&kafka.Message{Key: []byte("key"), Value: []byte{1, 0, 1, 134, 161}}))
+	req.Empty(decodeSchemaIDs(&kafka.Message{Value: []byte{0, 0, 1}}))
+}
+
+func newMockConsumer(req *require.Assertions, cluster *kafka.MockCluster) *kafka.Consumer {
+	ccfg, err := createConsumerConfig(cluster.BootstrapServers(), Credentials{})
+	req.NoError(err)
+	req.NoError(ccfg.SetKey("security.protocol", "PLAINTEXT"))
+	consumer, err := newConsumer(ccfg)
+	req.NoError(err)
+	return consumer
+}
+
+// requireConsumersClosed checks that every consumer created by the test has been closed with CloseConsumer once
+// it is done.
+func requireConsumersClosed(t *testing.T) {
+	t.Cleanup(func() {
+		require.Zero(t, atomic.LoadInt32(&openConsumers))
+	})
+}
+
+// produceTransactions produces one message per transaction to partition 0 of the topic, with the given schema
+// IDs in the values, committing or aborting each transaction as told.
+func produceTransactions(req *require.Assertions, bootstrapServers string, topic string, schemaIDs []byte, commits []bool) {
+	producer, err := kafka.NewProducer(&kafka.ConfigMap{
+		"bootstrap.servers": bootstrapServers,
+		"transactional.id":  "schema-deletion-tool-test",
+	})
+	req.NoError(err)
+	defer producer.Close()
+	req.NoError(producer.InitTransactions(nil))
+	deliveryChan := make(chan kafka.Event, 1)
+	for i, schemaID := range schemaIDs {
+		req.NoError(producer.BeginTransaction())
+		req.NoError(producer.Produce(&kafka.Message{
+			TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 0},
+			Value:          []byte{MagicByte, 0, 0, 0, schemaID, 'v'},
+		}, deliveryChan))
+		// Make sure aborted messages are written to the log as well.
+		req.NoError((<-deliveryChan).(*kafka.Message).TopicPartition.Error)
+		if commits[i] {
+			req.NoError(producer.CommitTransaction(nil))
+		} else {
+			req.NoError(producer.AbortTransaction(nil))
+		}
+	}
+}
+
+func TestScanActiveSchemasMockCluster(t *testing.T) {
+	req := require.New(t)
+	requireConsumersClosed(t)
+	cluster, err := kafka.NewMockCluster(1)
+	req.NoError(err)
+	defer cluster.Close()
+
+	topic := "transactional-topic"
+	produceTransactions(req, cluster.BootstrapServers(), topic, []byte{1, 2, 3}, []bool{true, true, true})
+
+	consumer := newMockConsumer(req, cluster)
+	defer CloseConsumer(consumer)
+	usages, highWatermarks, err := scanActiveSchemas(context.Background(), consumer, topic)
+	req.NoError(err)
+	req.Equal(kafka.Offset(3), highWatermarks[0])
+	for i, schemaID := range []int32{1, 2, 3} {
+		req.Contains(usages, schemaID)
+		req.Equal(VALUEONLY, usages[schemaID].Type)
+		req.Equal(int64(1), usages[schemaID].Messages)
+		req.Equal(int64(1), usages[schemaID].ValueMessages)
+		req.Zero(usages[schemaID].KeyMessages)
+		req.Equal(map[int32]kafka.Offset{0: kafka.Offset(i)}, usages[schemaID].FirstOffsets)
+		req.False(usages[schemaID].LastSeen.IsZero())
+		req.Equal(usages[schemaID].LastSeen, usages[schemaID].LastValueSeen)
+		req.Zero(usages[schemaID].LastKeySeen)
+	}
+	// Other partitions of the topic are empty.
+	req.Len(highWatermarks, 4)
+	req.Equal(kafka.Offset(0), highWatermarks[1])
+}
+
+// TestScanAbortedTransactions needs a real broker, given by TEST_BOOTSTRAP_SERVERS: the mock cluster neither
+// writes transaction markers nor filters out aborted records when reading committed messages.
+func TestScanAbortedTransactions(t *testing.T) {
+	bootstrapServers := os.Getenv("TEST_BOOTSTRAP_SERVERS")
+	if len(bootstrapServers) == 0 {
+		t.Skip("TEST_BOOTSTRAP_SERVERS is not set")
+	}
+	req := require.New(t)
+	requireConsumersClosed(t)
+	topic := fmt.Sprintf("schema-deletion-tool-test-%d", time.Now().UnixNano())
+	admin, err := kafka.NewAdminClient(&kafka.ConfigMap{"bootstrap.servers": bootstrapServers})
+	req.NoError(err)
+	defer admin.Close()
+	results, err := admin.CreateTopics(context.Background(), []kafka.TopicSpecification{{Topic: topic, NumPartitions: 1, ReplicationFactor: 1}})
+	req.NoError(err)
+	req.Equal(kafka.ErrNoError, results[0].Error.Code())
+	defer admin.DeleteTopics(context.Background(), []string{topic})
+
+	// Schema 2 is only used by an aborted transaction, and the last offset of the topic is a commit marker.
+	produceTransactions(req, bootstrapServers, topic, []byte{1, 2, 3}, []bool{true, false, true})
+
+	scan := func(isolationLevel string) map[int32]int {
+		ccfg, err := createConsumerConfig(bootstrapServers, Credentials{})
+		req.NoError(err)
+		req.NoError(ccfg.SetKey("security.protocol", "PLAINTEXT"))
+		req.NoError(ccfg.SetKey("isolation.level", isolationLevel))
+		consumer, err := newConsumer(ccfg)
+		req.NoError(err)
+		defer CloseConsumer(consumer)
+		runCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		usages, _, err := scanActiveSchemas(runCtx, consumer, topic)
+		req.NoError(err)
+		return usageTypes(usages)
+	}
+	committed := scan(ReadCommitted)
+	req.Equal(map[int32]int{1: VALUEONLY, 3: VALUEONLY}, committed)
+	uncommitted := scan(ReadUncommitted)
+	req.Equal(map[int32]int{1: VALUEONLY, 2: VALUEONLY, 3: VALUEONLY}, uncommitted)
+	req.Equal(map[int32]int{2: VALUEONLY}, uncommittedOnly(uncommitted, committed))
+}
+
+func TestConsumeRangeTrailingNonDeliverableRecords(t *testing.T) {
+	req := require.New(t)
+	requireConsumersClosed(t)
+	cluster, err := kafka.NewMockCluster(1)
+	req.NoError(err)
+	defer cluster.Close()
+
+	topic := "compacted-topic"
+	produceTransactions(req, cluster.BootstrapServers(), topic, []byte{1, 2}, []bool{true, true})
+
+	// The mock cluster doesn't compact logs, so the end offset is pushed past the last record to simulate trailing
+	// records that are never delivered, as left by compaction. Transaction markers are covered by
+	// TestScanAbortedTransactions.
+	for _, trailing := range []kafka.Offset{1, 2, 5} {
+		consumer := newMockConsumer(req, cluster)
+		startOffsets := map[int32]kafka.Offset{0: 0, 1: 0, 2: 0, 3: 0}
+		endOffsets := map[int32]kafka.Offset{0: 2 + trailing, 1: 0, 2: 0, 3: 0}
+		usages, totalMsg, err := consumeRange(context.Background(), consumer, topic, startOffsets, endOffsets, nil, nil)
+		req.NoError(err)
+		req.Equal(int64(2+trailing), totalMsg)
+		req.Equal(map[int32]int{1: VALUEONLY, 2: VALUEONLY}, usageTypes(usages))
+		CloseConsumer(consumer)
+	}
+
+	// Records past the end offsets are not taken into account.
+	consumer := newMockConsumer(req, cluster)
+	defer CloseConsumer(consumer)
+	usages, _, err := consumeRange(context.Background(), consumer, topic, map[int32]kafka.Offset{0: 0}, map[int32]kafka.Offset{0: 1}, nil, nil)
+	req.NoError(err)
+	req.Equal(map[int32]int{1: VALUEONLY}, usageTypes(usages))
+}
+
+func TestUncommittedOnly(t *testing.T) {
+	req := require.New(t)
+	req.Equal(map[int32]int{2: VALUEONLY, 3: KEYVALUE}, uncommittedOnly(
+		map[int32]int{1: KEYVALUE, 2: KEYVALUE, 3: KEYVALUE},
+		map[int32]int{1: KEYVALUE, 2: KEYONLY}))
+	req.Empty(uncommittedOnly(map[int32]int{1: VALUEONLY}, map[int32]int{1: KEYVALUE}))
+}
+
+func TestConsumeRangeCancelled(t *testing.T) {
+	req := require.New(t)
+	requireConsumersClosed(t)
+	cluster, err := kafka.NewMockCluster(1)
+	req.NoError(err)
+	defer cluster.Close()
+
+	topic := "cancelled-topic"
+	produceTransactions(req, cluster.BootstrapServers(), topic, []byte{1}, []bool{true})
+
+	// Each case uses its own consumer, as partitions are never assigned once the scan is cancelled.
+	consumer := newMockConsumer(req, cluster)
+	runCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err = consumeRange(runCtx, consumer, topic, map[int32]kafka.Offset{0: 0}, map[int32]kafka.Offset{0: 1}, nil, nil)
+	req.Equal(context.Canceled, err)
+	assignment, err := consumer.Assignment()
+	req.NoError(err)
+	req.Empty(assignment)
+	CloseConsumer(consumer)
+
+	consumer = newMockConsumer(req, cluster)
+	defer CloseConsumer(consumer)
+	runCtx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-runCtx.Done()
+	_, _, err = consumeRange(runCtx, consumer, topic, map[int32]kafka.Offset{0: 0}, map[int32]kafka.Offset{0: 1}, nil, nil)
+	req.Equal(context.DeadlineExceeded, err)
+}
+
+func TestSampleRecentSchemasMockCluster(t *testing.T) {
+	req := require.New(t)
+	requireConsumersClosed(t)
+	cluster, err := kafka.NewMockCluster(1)
+	req.NoError(err)
+	defer cluster.Close()
+
+	topic := "orders-app-store-changelog"
+	produceTransactions(req, cluster.BootstrapServers(), topic, []byte{1, 2, 3}, []bool{true, true, true})
+
+	consumer := newMockConsumer(req, cluster)
+	defer CloseConsumer(consumer)
+	schemaIDs, err := sampleRecentSchemas(context.Background(), consumer, topic, 2)
+	req.NoError(err)
+	req.Equal(map[int32]int{2: VALUEONLY, 3: VALUEONLY}, schemaIDs)
+}
+
+func TestConsumeRangeResumedPastEnd(t *testing.T) {
+	req := require.New(t)
+	requireConsumersClosed(t)
+	cluster, err := kafka.NewMockCluster(1)
+	req.NoError(err)
+	defer cluster.Close()
+
+	topic := "resumed-topic"
+	produceTransactions(req, cluster.BootstrapServers(), topic, []byte{1, 2, 3}, []bool{true, true, true})
+	producer, err := kafka.NewProducer(&kafka.ConfigMap{"bootstrap.servers": cluster.BootstrapServers()})
+	req.NoError(err)
+	defer producer.Close()
+	deliveryChan := make(chan kafka.Event, 1)
+	req.NoError(producer.Produce(&kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 1},
+		Value:          []byte{MagicByte, 0, 0, 0, 9, 'v'},
+	}, deliveryChan))
+	req.NoError((<-deliveryChan).(*kafka.Message).TopicPartition.Error)
+
+	checkpointInterval := CheckpointInterval
+	CheckpointInterval = 0
+	defer func() { CheckpointInterval = checkpointInterval }()
+	endOffsets := map[int32]kafka.Offset{0: 2, 1: 1}
+	onCheckpoint := func(positions map[int32]kafka.Offset, usages map[int32]*SchemaUsage) {
+		for partition, position := range positions {
+			req.LessOrEqual(int64(position), int64(endOffsets[partition]))
+		}
+	}
+
+	// A checkpoint past the end of a partition does not keep the other partitions from being scanned.
+	consumer := newMockConsumer(req, cluster)
+	usages, totalMsg, err := consumeRange(context.Background(), consumer, topic, map[int32]kafka.Offset{0: 3, 1: 0}, endOffsets, onCheckpoint, nil)
+	CloseConsumer(consumer)
+	req.NoError(err)
+	req.Equal(int64(1), totalMsg)
+	req.Equal(map[int32]int{9: VALUEONLY}, usageTypes(usages))
+
+	// Messages past the end are never checkpointed as scanned.
+	consumer = newMockConsumer(req, cluster)
+	usages, _, err = consumeRange(context.Background(), consumer, topic, map[int32]kafka.Offset{0: 0, 1: 0}, endOffsets, onCheckpoint, nil)
+	CloseConsumer(consumer)
+	req.NoError(err)
+	req.Equal(map[int32]int{1: VALUEONLY, 2: VALUEONLY, 9: VALUEONLY}, usageTypes(usages))
+}
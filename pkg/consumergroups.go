@@ -0,0 +1,144 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+const (
+	BehindAllConsumers = "behind all consumers"
+	PendingConsumption = "pending consumption"
+)
+
+var SchemaConsumptionFields = []interface{}{"SchemaID", "Subject", "Version", "Consumption"}
+
+type ConsumerGroup struct {
+	ID string `json:"consumer_group_id"`
+}
+
+type SchemaConsumption struct {
+	SchemaID    json.Number
+	Subject     string
+	Version     json.Number
+	Consumption string
+}
+
+// ApplyConsumerGroupPolicy returns the usages pending consumption by some consumer group of their topic, which
+// are the only ones protecting schemas from deletion.
+func ApplyConsumerGroupPolicy(runCtx context.Context, ctx *Context, schemas []SchemaInfo, usedSchemas map[int32]int) (map[int32]int, error) {
+	fmt.Println("Fetching committed offsets of consumer groups for the scanned topics...")
+	var clusters []string
+	scansByCluster := make(map[string][]*TopicScan)
+	for _, scan := range ctx.ScannedTopics() {
+		if _, ok := scansByCluster[scan.ClusterID]; !ok {
+			clusters = append(clusters, scan.ClusterID)
+		}
+		scansByCluster[scan.ClusterID] = append(scansByCluster[scan.ClusterID], scan)
+	}
+	pendingSchemas := make(map[int32]int)
+	for _, cluster := range clusters {
+		scans := scansByCluster[cluster]
+		committedOffsets, err := fetchClusterCommittedOffsets(runCtx, ctx, cluster, scans)
+		if err != nil {
+			return nil, err
+		}
+		for _, scan := range scans {
+			for schemaID, usage := range scan.Usages {
+				if usage.Type&KEYONLY != 0 && isPendingConsumption(usage.LastKeyOffsets, committedOffsets[scan.Topic]) {
+					pendingSchemas[schemaID] = pendingSchemas[schemaID] | KEYONLY
+				}
+				if usage.Type&VALUEONLY != 0 && isPendingConsumption(usage.LastValueOffsets, committedOffsets[scan.Topic]) {
+					pendingSchemas[schemaID] = pendingSchemas[schemaID] | VALUEONLY
+				}
+			}
+		}
+	}
+
+	var consumptions []SchemaConsumption
+	for _, schema := range schemas {
+		if !isSchemaUsed(schema, usedSchemas) {
+			continue
+		}
+		consumption := BehindAllConsumers
+		if isSchemaUsed(schema, pendingSchemas) {
+			consumption = PendingConsumption
+		}
+		consumptions = append(consumptions, SchemaConsumption{schema.SchemaID, schema.Subject, schema.Version, consumption})
+	}
+	fmt.Printf("Following %d schemas are in use. Schemas whose usages are all %s qualify for deletion.\n", len(consumptions), BehindAllConsumers)
+	PrintTable(SchemaConsumptionFields, consumptions, false)
+	return pendingSchemas, nil
+}
+
+// isPendingConsumption checks whether some consumer group has not yet committed past the last offsets of a
+// schema usage. Usages in topics without any consumer group are always pending consumption.
+func isPendingConsumption(lastOffsets map[int32]kafka.Offset, committedOffsets map[string]map[int32]kafka.Offset) bool {
+	if len(committedOffsets) == 0 {
+		return true
+	}
+	for partition, offset := range lastOffsets {
+		for _, offsets := range committedOffsets {
+			committed, ok := offsets[partition]
+			if !ok || committed <= offset {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fetchClusterCommittedOffsets returns the offsets committed for the scanned topics of a cluster, by topic and
+// group. The groups of self-managed clusters cannot be listed, so none are returned.
+func fetchClusterCommittedOffsets(runCtx context.Context, ctx *Context, cluster string, scans []*TopicScan) (map[string]map[string]map[int32]kafka.Offset, error) {
+	committedOffsets := make(map[string]map[string]map[int32]kafka.Offset)
+	if len(ctx.Topology.cluster(cluster).BootstrapServers) > 0 {
+		fmt.Printf("Consumer groups of self-managed cluster %s cannot be listed, its usages are all %s.\n", cluster, PendingConsumption)
+		return committedOffsets, nil
+	}
+	groups, err := listConsumerGroups(runCtx, ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+	partitions := make(map[string]map[int32]kafka.Offset)
+	for _, scan := range scans {
+		partitions[scan.Topic] = scan.HighWatermarks
+	}
+	for _, group := range groups {
+		if runCtx.Err() != nil {
+			return nil, runCtx.Err()
+		}
+		offsets, err := fetchCommittedOffsets(scans[0].Endpoint, ctx.Credentials[cluster], group, partitions)
+		if err != nil {
+			return nil, err
+		}
+		for topic, topicOffsets := range offsets {
+			if len(topicOffsets) == 0 {
+				continue
+			}
+			if committedOffsets[topic] == nil {
+				committedOffsets[topic] = make(map[string]map[int32]kafka.Offset)
+			}
+			committedOffsets[topic][group] = topicOffsets
+		}
+	}
+	return committedOffsets, nil
+}
+
+func listConsumerGroups(runCtx context.Context, ctx *Context, cluster string) ([]string, error) {
+	output, err := ExecuteCommand(runCtx, Confluent, ctx.withClusterEnvironment(cluster, []string{"kafka", "consumer", "group", "list", "--cluster", cluster, "-o", "json"}), false)
+	if err != nil {
+		return nil, err
+	}
+	var consumerGroups []ConsumerGroup
+	if err = json.Unmarshal(output, &consumerGroups); err != nil {
+		return nil, err
+	}
+	var groups []string
+	for _, group := range consumerGroups {
+		groups = append(groups, group.ID)
+	}
+	return groups, nil
+}
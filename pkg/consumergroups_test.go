@@ -0,0 +1,40 @@
+package pkg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsPendingConsumption(t *testing.T) {
+	req := require.New(t)
+	lastOffsets := map[int32]kafka.Offset{0: 10, 1: 0}
+	req.True(isPendingConsumption(lastOffsets, map[string]map[int32]kafka.Offset{}))
+	req.False(isPendingConsumption(lastOffsets, map[string]map[int32]kafka.Offset{
+		"group-1": {0: 11, 1: 1},
+		"group-2": {0: 20, 1: 5},
+	}))
+	req.True(isPendingConsumption(lastOffsets, map[string]map[int32]kafka.Offset{
+		"group-1": {0: 11, 1: 1},
+		"group-2": {0: 10, 1: 5},
+	}))
+	req.True(isPendingConsumption(lastOffsets, map[string]map[int32]kafka.Offset{
+		"group-1": {0: 11},
+	}))
+}
+
+func TestConsumerGroupPolicySelfManagedCluster(t *testing.T) {
+	req := require.New(t)
+	ctx := &Context{
+		Topology: &Topology{Clusters: map[string]TopologyCluster{"onprem": {BootstrapServers: "kafka:9092"}}},
+		Scans: []*TopicScan{{TopicWithClusterInfo: TopicWithClusterInfo{"orders", "onprem"}, Status: SCANNED,
+			Usages: map[int32]*SchemaUsage{100001: {Type: VALUEONLY, LastValueOffsets: map[int32]kafka.Offset{0: 3}}}}},
+	}
+	schemas := []SchemaInfo{{SchemaID: "100001", Subject: "orders-value", Version: "1"}}
+	// The consumer groups of a self-managed cluster cannot be listed, so its usages are kept pending consumption.
+	pendingSchemas, err := ApplyConsumerGroupPolicy(context.Background(), ctx, schemas, map[int32]int{100001: VALUEONLY})
+	req.NoError(err)
+	req.Equal(map[int32]int{100001: VALUEONLY}, pendingSchemas)
+}
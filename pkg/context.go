@@ -1,9 +1,11 @@
 package pkg
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"time"
 
 	"github.com/havoc-io/gopass"
 )
@@ -13,6 +15,49 @@ type Context struct {
 	Clusters    []string
 	Subjects    []string
 	Topics      []string
+	Scans       []*TopicScan
+	// Environment is the ID of the environment the clusters and Schema Registry belong to.
+	Environment string
+	// SchemaContext is the schema context of the subjects, DEFAULT_CONTEXT for the default one.
+	SchemaContext string
+	// ContextClusters maps schema contexts to the clusters serving them; unmapped contexts are served by all.
+	ContextClusters map[string][]string
+	// Topology maps subjects and schema contexts to clusters outside of the environment.
+	Topology *Topology
+	// Exports are the schema exporters copying each exported subject.
+	Exports map[string][]string
+	// TopicMapping declares the topics of subjects besides the ones named after them.
+	TopicMapping map[string]*SubjectTopics
+	// DiscoverySamples is how many recent messages per partition are sampled for discovery, zero to skip it.
+	DiscoverySamples int64
+	// TopicSamples are the sampled topics of the environment, shared by its schema contexts.
+	TopicSamples []*TopicSample
+	// DiscoveredTopics are the topics found carrying schema IDs of the subjects, along with those subjects.
+	DiscoveredTopics map[TopicWithClusterInfo][]string
+	ScanAllTopics    bool
+	ScanMirrors      bool
+	// TopicScans are the scans of every topic of the environment, shared by its schema contexts.
+	TopicScans []*TopicScan
+	// UsageIndexFile is where the usage index is saved to, or reused from.
+	UsageIndexFile string
+	// UsageIndexMaxAge is how old a reused usage index may be, zero for no limit.
+	UsageIndexMaxAge time.Duration
+	// TopicTimeout limits scanning a single topic, zero for no limit.
+	TopicTimeout time.Duration
+	// ProgressFile is where the scan progress is checkpointed.
+	ProgressFile string
+	// Resume is the progress of a previous scan to continue from, nil to scan from scratch.
+	Resume         *ScanProgress
+	IsolationLevel string
+	RecheckPolicy  string
+	// WatchWindow is how long to tail the scanned topics after soft deleting, zero to skip watching.
+	WatchWindow  time.Duration
+	AutoRollback bool
+	// StaleAfter is how old the newest message using a schema must be for it to be stale, zero to disable.
+	StaleAfter time.Duration
+
+	// registeredSchemas are all the schemas registered in Schema Registry, listed once when first needed.
+	registeredSchemas []SchemaInfo
 }
 
 func NewContext(configFile string) (*Context, error) {
@@ -25,18 +70,129 @@ func NewContext(configFile string) (*Context, error) {
 		}
 	}
 	return &Context{
-		Credentials: credentials,
+		Credentials:    credentials,
+		SchemaContext:  DEFAULT_CONTEXT,
+		RecheckPolicy:  RecheckDrop,
+		IsolationLevel: ReadCommitted,
 	}, nil
 }
 
+// ForSchemaContext returns a copy of the context to clean up the given subjects of a schema context, scanning
+// only the selected clusters serving that context.
+func (ctx *Context) ForSchemaContext(schemaContext string, subjects []string) *Context {
+	contextCtx := *ctx
+	contextCtx.SchemaContext = schemaContext
+	contextCtx.Subjects = subjects
+	contextCtx.Topics = ctx.TopicsOf(subjects)
+	contextCtx.Scans = nil
+	contextCtx.DiscoveredTopics = nil
+	contextCtx.registeredSchemas = nil
+	// Nothing that a schema context may change is shared with the others.
+	contextCtx.Resume = ctx.Resume.clone()
+	contextCtx.Credentials = make(map[string]Credentials)
+	for cluster, credentials := range ctx.Credentials {
+		contextCtx.Credentials[cluster] = credentials
+	}
+	if clusters, ok := ctx.ContextClusters[schemaContext]; ok {
+		contextCtx.Clusters = nil
+		for _, cluster := range ctx.Clusters {
+			if ContainsTopic(cluster, clusters) {
+				contextCtx.Clusters = append(contextCtx.Clusters, cluster)
+			}
+		}
+	}
+	return &contextCtx
+}
+
+// LoadContextClusters reads the mapping of schema contexts to the clusters whose topics they serve, e.g.
+// {".mycontext": ["lkc-123"], ".": ["lkc-456"]}.
+func LoadContextClusters(configFile string) (map[string][]string, error) {
+	content, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+	var mapping map[string][]string
+	if err = json.Unmarshal(content, &mapping); err != nil {
+		return nil, err
+	}
+	contextClusters := make(map[string][]string)
+	for schemaContext, clusters := range mapping {
+		contextClusters[NormalizeContext(schemaContext)] = clusters
+	}
+	return contextClusters, nil
+}
+
 func (ctx *Context) SetClusters(clusters []string) error {
 	if err := ctx.promptCredentials(clusters); err != nil {
 		return err
 	}
+	// Clusters outside of the environment are always scanned for the subjects the topology maps to them.
+	if err := ctx.promptCredentials(ctx.topologyClusters()); err != nil {
+		return err
+	}
 	ctx.Clusters = clusters
 	return nil
 }
 
+// RegisteredSchemas returns all the schemas registered in the schema context, soft deleted included.
+func (ctx *Context) RegisteredSchemas(runCtx context.Context) ([]SchemaInfo, error) {
+	if ctx.registeredSchemas != nil {
+		return ctx.registeredSchemas, nil
+	}
+	args := []string{"schema-registry", "schema", "list", "--all", "-o", "json"}
+	if prefix := ContextPrefix(ctx.SchemaContext); len(prefix) > 0 {
+		args = append(args, "--subject-prefix", prefix)
+	}
+	output, err := ExecuteCommand(runCtx, Confluent, ctx.withEnvironment(args), false)
+	if err != nil {
+		return nil, credentialsError("listing all schemas")
+	}
+	var listed []SchemaInfo
+	if err = json.Unmarshal(output, &listed); err != nil {
+		return nil, err
+	}
+	ctx.registeredSchemas = contextSchemas(listed, ctx.SchemaContext)
+	return ctx.registeredSchemas, nil
+}
+
+// contextSchemas keeps the schemas of a schema context only. The default context has no prefix to list its
+// schemas by, and schema IDs are only unique within a context.
+func contextSchemas(schemas []SchemaInfo, schemaContext string) []SchemaInfo {
+	filtered := make([]SchemaInfo, 0)
+	for _, schema := range schemas {
+		if SubjectContext(schema.Subject) == NormalizeContext(schemaContext) {
+			filtered = append(filtered, schema)
+		}
+	}
+	return filtered
+}
+
+// AnyNamingStrategy tells whether subjects of any naming strategy can be cleaned up, which takes scanning every
+// topic as their topics cannot be told from their name.
+func (ctx *Context) AnyNamingStrategy() bool {
+	return ctx.ScanAllTopics || len(ctx.UsageIndexFile) > 0
+}
+
+// withEnvironment adds the environment of the context to the arguments of a Confluent CLI command, so that
+// it does not depend on the environment in use by the CLI.
+func (ctx *Context) withEnvironment(args []string) []string {
+	if len(ctx.Environment) == 0 {
+		return args
+	}
+	return append(args, "--environment", ctx.Environment)
+}
+
+// ScannedTopics returns the topic scans that have completed successfully.
+func (ctx *Context) ScannedTopics() []*TopicScan {
+	var scans []*TopicScan
+	for _, scan := range ctx.Scans {
+		if scan.Status == SCANNED {
+			scans = append(scans, scan)
+		}
+	}
+	return scans
+}
+
 func loadConfig(configFile string) (map[string]Credentials, error) {
 	content, err := ioutil.ReadFile(configFile)
 	if err != nil {
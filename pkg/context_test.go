@@ -0,0 +1,56 @@
+package pkg
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestForSchemaContext(t *testing.T) {
+	req := require.New(t)
+	configFile := filepath.Join(t.TempDir(), "contexts.json")
+	req.NoError(ioutil.WriteFile(configFile, []byte(`{"mycontext": ["lkc-123", "lkc-789"], ".": ["lkc-456"]}`), 0600))
+	contextClusters, err := LoadContextClusters(configFile)
+	req.NoError(err)
+	req.Equal(map[string][]string{".mycontext": {"lkc-123", "lkc-789"}, DEFAULT_CONTEXT: {"lkc-456"}}, contextClusters)
+
+	ctx := &Context{
+		Clusters:        []string{"lkc-123", "lkc-456"},
+		ContextClusters: contextClusters,
+		Scans:           []*TopicScan{{TopicWithClusterInfo: TopicWithClusterInfo{"orders", "lkc-123"}}},
+		Credentials:     map[string]Credentials{"lkc-123": {"key", "secret"}},
+		Resume: &ScanProgress{Topics: []TopicProgress{{Topic: "orders", ClusterID: "lkc-123",
+			Usages: map[int32]*SchemaUsage{100001: {Messages: 1}}}}},
+	}
+	contextCtx := ctx.ForSchemaContext(".mycontext", []string{":.mycontext:orders-value"})
+	req.Equal(".mycontext", contextCtx.SchemaContext)
+	req.Equal([]string{"lkc-123"}, contextCtx.Clusters)
+	req.Equal([]string{"orders"}, contextCtx.Topics)
+	req.Empty(contextCtx.Scans)
+	req.Len(ctx.Scans, 1)
+
+	// The resumed progress and the credentials are copied, not shared.
+	req.Equal(ctx.Resume, contextCtx.Resume)
+	req.Equal(ctx.Credentials, contextCtx.Credentials)
+	contextCtx.Resume.Topics[0].Usages[100001].Messages++
+	contextCtx.Credentials["lkc-456"] = Credentials{"key", "secret"}
+	req.Equal(int64(1), ctx.Resume.Topics[0].Usages[100001].Messages)
+	req.NotContains(ctx.Credentials, "lkc-456")
+
+	delete(ctx.ContextClusters, DEFAULT_CONTEXT)
+	req.Equal(ctx.Clusters, ctx.ForSchemaContext(DEFAULT_CONTEXT, []string{"orders-value"}).Clusters)
+}
+
+func TestContextSchemas(t *testing.T) {
+	req := require.New(t)
+	schemas := []SchemaInfo{
+		{SchemaID: "100001", Subject: "orders-value", Version: "1"},
+		{SchemaID: "100001", Subject: ":.mycontext:orders-value", Version: "1"},
+		{SchemaID: "100002", Subject: ":.other:orders-value", Version: "1"},
+	}
+	req.Equal(schemas[:1], contextSchemas(schemas, DEFAULT_CONTEXT))
+	req.Equal(schemas[1:2], contextSchemas(schemas, ".mycontext"))
+	req.Empty(contextSchemas(schemas, ".unknown"))
+}
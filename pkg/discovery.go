@@ -0,0 +1,131 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SampleTopics samples the most recent messages of every topic of the clusters, once per environment.
+func SampleTopics(runCtx context.Context, ctx *Context) error {
+	fmt.Printf("Sampling the %d most recent messages of each partition of every topic to discover topics using the "+
+		"schemas...\n", ctx.DiscoverySamples)
+	ctx.TopicSamples = nil
+	clusters, _ := ctx.topicsByCluster()
+	for _, cluster := range clusters {
+		topics, err := listClusterTopics(runCtx, ctx, cluster)
+		if runCtx.Err() != nil {
+			return runCtx.Err()
+		}
+		if err != nil {
+			fmt.Printf("%sFailed to list topics of cluster %s: %v%s\n", RED, cluster, err, RESET)
+			ctx.TopicSamples = append(ctx.TopicSamples, &TopicSample{TopicWithClusterInfo: TopicWithClusterInfo{ALL_TOPICS, cluster}, Error: err.Error()})
+			continue
+		}
+		var candidates []string
+		for _, topic := range topics {
+			if !isInternalTopic(topic.Name) {
+				candidates = append(candidates, topic.Name)
+			}
+		}
+		if len(candidates) == 0 {
+			continue
+		}
+		endpoint, err := clusterEndpoint(runCtx, ctx, cluster)
+		if err != nil {
+			fmt.Printf("%sFailed to describe cluster %s, its topics are not sampled: %v%s\n", RED, cluster, err, RESET)
+			ctx.TopicSamples = append(ctx.TopicSamples, &TopicSample{TopicWithClusterInfo: TopicWithClusterInfo{ALL_TOPICS, cluster}, Error: err.Error()})
+			continue
+		}
+		for _, topic := range candidates {
+			sample := &TopicSample{TopicWithClusterInfo: TopicWithClusterInfo{topic, cluster}}
+			sample.SchemaIDs, err = sampleTopic(runCtx, ctx, endpoint, sample.TopicWithClusterInfo)
+			if runCtx.Err() != nil {
+				return runCtx.Err()
+			}
+			if err != nil {
+				fmt.Printf("%sFailed to sample topic %s from cluster %s: %v%s\n", RED, topic, cluster, err, RESET)
+				sample.Error = err.Error()
+			}
+			ctx.TopicSamples = append(ctx.TopicSamples, sample)
+		}
+	}
+	return nil
+}
+
+// DiscoverTopics adds to the scan the sampled topics carrying schema IDs of the subjects without being named after
+// them. Topics that could not be sampled are recorded as failed scans.
+func DiscoverTopics(ctx *Context, schemas []SchemaInfo) {
+	ctx.DiscoveredTopics = make(map[TopicWithClusterInfo][]string)
+	clusters, clusterTopics := ctx.topicsByCluster()
+	var failed int
+	for _, sample := range ctx.TopicSamples {
+		if !ContainsTopic(sample.ClusterID, clusters) || clusterTopics[sample.ClusterID].matches(sample.Topic) {
+			continue
+		}
+		if len(sample.Error) > 0 {
+			ctx.Scans = append(ctx.Scans, &TopicScan{TopicWithClusterInfo: sample.TopicWithClusterInfo,
+				Status: SCAN_FAILED, Error: "sampling failed: " + sample.Error})
+			failed++
+			continue
+		}
+		if subjects := subjectsUsing(schemas, sample.SchemaIDs); len(subjects) > 0 {
+			ctx.DiscoveredTopics[sample.TopicWithClusterInfo] = subjects
+		}
+	}
+	fmt.Printf("Discovered %d topic(s) carrying schema IDs of the subjects.\n", len(ctx.DiscoveredTopics))
+	if failed > 0 {
+		fmt.Printf("%sFailed to sample %d topic(s) or cluster(s), the usage of every subject is unknown.%s\n", RED, failed, RESET)
+	}
+}
+
+// sampleFailed tells whether a topic could not be sampled, or the topics of its cluster could not be listed.
+func (ctx *Context) sampleFailed(topic TopicWithClusterInfo) bool {
+	for _, sample := range ctx.TopicSamples {
+		if sample.ClusterID == topic.ClusterID && (sample.Topic == topic.Topic || sample.Topic == ALL_TOPICS) && len(sample.Error) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func isInternalTopic(topic string) bool {
+	return strings.HasPrefix(topic, "_")
+}
+
+func sampleTopic(runCtx context.Context, ctx *Context, endpoint string, topic TopicWithClusterInfo) (map[int32]int, error) {
+	consumer, err := CreateConsumer(endpoint, ctx.Credentials[topic.ClusterID], ctx.IsolationLevel)
+	if err != nil {
+		return nil, err
+	}
+	defer CloseConsumer(consumer)
+	topicCtx, cancel := runCtx, context.CancelFunc(func() {})
+	if ctx.TopicTimeout > 0 {
+		topicCtx, cancel = context.WithTimeout(runCtx, ctx.TopicTimeout)
+	}
+	defer cancel()
+	return sampleRecentSchemas(topicCtx, consumer, topic.Topic, ctx.DiscoverySamples)
+}
+
+// subjectsUsing returns the subjects of the schemas whose ID is used, in message keys or values alike, as derived
+// topics don't necessarily keep the role of the data they are derived from.
+func subjectsUsing(schemas []SchemaInfo, schemaIDs map[int32]int) []string {
+	var subjects []string
+	for _, schema := range schemas {
+		if _, ok := schemaIDs[schemaIDOf(schema)]; ok && !ContainsTopic(schema.Subject, subjects) {
+			subjects = append(subjects, schema.Subject)
+		}
+	}
+	sort.Strings(subjects)
+	return subjects
+}
+
+// discoveryNote tells which subjects have their schema IDs in a discovered topic.
+func discoveryNote(ctx *Context, topic TopicWithClusterInfo) string {
+	subjects, ok := ctx.DiscoveredTopics[topic]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("discovered, carries IDs of %s", strings.Join(subjects, ", "))
+}
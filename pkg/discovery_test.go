@@ -0,0 +1,63 @@
+package pkg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscoveredTopics(t *testing.T) {
+	req := require.New(t)
+	schemas := []SchemaInfo{
+		{SchemaID: "100001", Subject: "orders-key", Version: "1"},
+		{SchemaID: "100002", Subject: "orders-value", Version: "1"},
+		{SchemaID: "100003", Subject: "payments-value", Version: "1"},
+	}
+	req.Equal([]string{"orders-key", "payments-value"}, subjectsUsing(schemas, map[int32]int{100001: VALUEONLY, 100003: VALUEONLY, 100009: KEYONLY}))
+	req.Empty(subjectsUsing(schemas, map[int32]int{100009: VALUEONLY}))
+
+	changelog := TopicWithClusterInfo{"orders-app-store-changelog", "lkc-123"}
+	ctx := &Context{
+		Subjects:         []string{"orders-key", "orders-value", "payments-value"},
+		DiscoveredTopics: map[TopicWithClusterInfo][]string{changelog: {"orders-key", "payments-value"}},
+	}
+	req.Equal("discovered, carries IDs of orders-key, payments-value", discoveryNote(ctx, changelog))
+	req.Empty(discoveryNote(ctx, TopicWithClusterInfo{"orders", "lkc-123"}))
+
+	ctx.Scans = []*TopicScan{{TopicWithClusterInfo: changelog, Status: SCAN_FAILED}}
+	req.Equal([]string{"orders-key", "payments-value"}, UnknownUsageSubjects(ctx))
+}
+
+func TestDiscoverTopicsFromSamples(t *testing.T) {
+	req := require.New(t)
+	schemas := []SchemaInfo{
+		{SchemaID: "100001", Subject: "orders-value", Version: "1"},
+		{SchemaID: "100002", Subject: "payments-value", Version: "1"},
+	}
+	changelog := TopicWithClusterInfo{"orders-app-store-changelog", "lkc-123"}
+	ctx := &Context{
+		Clusters: []string{"lkc-123"},
+		Subjects: []string{"orders-value", "payments-value"},
+		Topics:   []string{"orders", "payments"},
+		TopicSamples: []*TopicSample{
+			{TopicWithClusterInfo: changelog, SchemaIDs: map[int32]int{100001: VALUEONLY}},
+			{TopicWithClusterInfo: TopicWithClusterInfo{"orders", "lkc-123"}, SchemaIDs: map[int32]int{100001: VALUEONLY}},
+			{TopicWithClusterInfo: TopicWithClusterInfo{"retries", "lkc-123"}, SchemaIDs: map[int32]int{100009: VALUEONLY}},
+			// Samples of clusters not serving the schema context are left out.
+			{TopicWithClusterInfo: TopicWithClusterInfo{"dlq", "lkc-456"}, SchemaIDs: map[int32]int{100002: VALUEONLY}},
+		},
+	}
+	DiscoverTopics(ctx, schemas)
+	req.Equal(map[TopicWithClusterInfo][]string{changelog: {"orders-value"}}, ctx.DiscoveredTopics)
+	req.Empty(ctx.Scans)
+	req.Empty(UnknownUsageSubjects(ctx))
+
+	// A topic that could not be sampled may carry schema IDs of any subject.
+	failed := TopicWithClusterInfo{"audit", "lkc-123"}
+	ctx.TopicSamples = append(ctx.TopicSamples, &TopicSample{TopicWithClusterInfo: failed, Error: "timed out"})
+	DiscoverTopics(ctx, schemas)
+	req.Len(ctx.Scans, 1)
+	req.Equal(failed, ctx.Scans[0].TopicWithClusterInfo)
+	req.Equal(SCAN_FAILED, ctx.Scans[0].Status)
+	req.Equal(ctx.Subjects, UnknownUsageSubjects(ctx))
+}
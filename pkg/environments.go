@@ -0,0 +1,120 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+var EnvironmentReportFields = []interface{}{"Environment", "Subjects", "ScannedTopics", "FailedTopics", "SoftDeleted", "HardDeleted", "Quarantined", "Purged", "Result"}
+
+// EnvironmentReport sums up the cleanup of an environment when cleaning up several environments in one run.
+type EnvironmentReport struct {
+	Environment   string
+	Subjects      int
+	ScannedTopics int
+	FailedTopics  int
+	DeletionOutcome
+	Result string
+}
+
+// DeletionOutcome tells what was deleted while cleaning up, and whether the user declined a hard deletion.
+// Schemas left soft deleted count as soft deleted only.
+type DeletionOutcome struct {
+	SoftDeleted Deletions
+	HardDeleted Deletions
+	Quarantined Deletions
+	Purged      Deletions
+	Declined    bool
+}
+
+// Deletions counts the deleted schema versions, and the subjects deleted as a whole along with them.
+type Deletions struct {
+	Schemas  int
+	Subjects int
+}
+
+func newDeletions(selection, schemas []SchemaInfo) Deletions {
+	subjects, _ := splitSubjectDeletions(selection, schemas)
+	return Deletions{len(selection), len(subjects)}
+}
+
+func (deletions Deletions) add(other Deletions) Deletions {
+	return Deletions{deletions.Schemas + other.Schemas, deletions.Subjects + other.Subjects}
+}
+
+func (deletions Deletions) String() string {
+	return fmt.Sprintf("%d (%d subject(s))", deletions.Schemas, deletions.Subjects)
+}
+
+// ListEnvironments returns the environments of the organization, and the ID of the one in use by the Confluent
+// CLI, if any.
+func ListEnvironments(runCtx context.Context) ([]Environment, string, error) {
+	output, err := ExecuteCommand(runCtx, Confluent, []string{"environment", "list", "-o", "json"}, false)
+	if err != nil {
+		return nil, "", err
+	}
+	var environments []Environment
+	if err = json.Unmarshal(output, &environments); err != nil {
+		return nil, "", err
+	}
+	var current string
+	for _, environment := range environments {
+		if environment.IsCurrent {
+			current = environment.ID
+		}
+	}
+	return environments, current, nil
+}
+
+// ForEnvironment returns a copy of the context to clean up an environment, with its own clusters, subjects and
+// Schema Registry. Credentials are shared, as cluster IDs are unique across environments.
+func (ctx *Context) ForEnvironment(environment string) *Context {
+	envCtx := *ctx
+	envCtx.Environment = environment
+	envCtx.Clusters = nil
+	envCtx.Subjects = nil
+	envCtx.Topics = nil
+	envCtx.Scans = nil
+	envCtx.TopicSamples = nil
+	envCtx.TopicScans = nil
+	envCtx.registeredSchemas = nil
+	return &envCtx
+}
+
+// Record adds the outcome of cleaning up the subjects of a schema context to the report.
+func (report *EnvironmentReport) Record(ctx *Context, outcome DeletionOutcome) {
+	report.Subjects += len(ctx.Subjects)
+	for _, scan := range ctx.Scans {
+		if scan.Status == SCANNED {
+			report.ScannedTopics++
+		} else {
+			report.FailedTopics++
+		}
+	}
+	report.SoftDeleted = report.SoftDeleted.add(outcome.SoftDeleted)
+	report.HardDeleted = report.HardDeleted.add(outcome.HardDeleted)
+	report.Quarantined = report.Quarantined.add(outcome.Quarantined)
+	report.Purged = report.Purged.add(outcome.Purged)
+	report.Declined = report.Declined || outcome.Declined
+	report.Result = report.result()
+}
+
+func (report *EnvironmentReport) result() string {
+	deleted := report.SoftDeleted.Schemas + report.HardDeleted.Schemas + report.Quarantined.Schemas + report.Purged.Schemas
+	switch {
+	case deleted == 0 && report.Declined:
+		return "hard deletion declined"
+	case deleted == 0:
+		return "no schemas deleted"
+	case report.Declined:
+		return "completed, hard deletion declined"
+	}
+	return "completed"
+}
+
+// ReportEnvironments prints the consolidated report of a run over several environments.
+func ReportEnvironments(reports []EnvironmentReport) {
+	fmt.Printf("Cleaned up %d environment(s).\n", len(reports))
+	PrintTable(EnvironmentReportFields, reports, false)
+}
@@ -0,0 +1,42 @@
+package pkg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestForEnvironment(t *testing.T) {
+	req := require.New(t)
+	ctx := &Context{
+		Environment: "env-123",
+		Credentials: map[string]Credentials{"lkc-123": {"key", "secret"}},
+		Clusters:    []string{"lkc-123"},
+		Subjects:    []string{"orders-value"},
+		Scans:       []*TopicScan{{TopicWithClusterInfo: TopicWithClusterInfo{"orders", "lkc-123"}, Status: SCANNED}},
+	}
+	envCtx := ctx.ForEnvironment("env-456")
+	req.Equal("env-456", envCtx.Environment)
+	req.Empty(envCtx.Clusters)
+	req.Empty(envCtx.Subjects)
+	req.Empty(envCtx.Scans)
+	req.Equal(ctx.Credentials, envCtx.Credentials)
+	req.Equal([]string{"schema-registry", "subject", "list", "--environment", "env-456"},
+		envCtx.withEnvironment([]string{"schema-registry", "subject", "list"}))
+
+	report := EnvironmentReport{Environment: "env-123"}
+	ctx.Scans = append(ctx.Scans, &TopicScan{TopicWithClusterInfo: TopicWithClusterInfo{"orders", "lkc-456"}, Status: SCAN_FAILED})
+	report.Record(ctx, DeletionOutcome{})
+	req.Equal(EnvironmentReport{Environment: "env-123", Subjects: 1, ScannedTopics: 1, FailedTopics: 1,
+		Result: "no schemas deleted"}, report)
+
+	// Declining every hard deletion is not reported as completed.
+	report = EnvironmentReport{Environment: "env-123"}
+	report.Record(ctx, DeletionOutcome{Declined: true})
+	req.Equal("hard deletion declined", report.Result)
+	report.Record(ctx, DeletionOutcome{SoftDeleted: Deletions{2, 1}, HardDeleted: Deletions{3, 0}})
+	req.Equal(Deletions{2, 1}, report.SoftDeleted)
+	req.Equal(Deletions{3, 0}, report.HardDeleted)
+	req.Equal("completed, hard deletion declined", report.Result)
+	req.Equal("2 (1 subject(s))", report.SoftDeleted.String())
+}
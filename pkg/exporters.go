@@ -0,0 +1,146 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// UnknownExporters stands for the exporters of subjects when schema exporters cannot be listed.
+const UnknownExporters = "unknown"
+
+var ExportedSchemaFields = []interface{}{"SchemaID", "Subject", "Version", "Exporters"}
+
+// SchemaExporter exports the subjects matching its filters to another Schema Registry.
+type SchemaExporter struct {
+	Name     string   `json:"name"`
+	Subjects []string `json:"subjects"`
+}
+
+type ExportedSchema struct {
+	SchemaID  json.Number
+	Subject   string
+	Version   json.Number
+	Exporters string
+}
+
+// ListExportedSubjects returns the schema exporters exporting each of the subjects of the context.
+func ListExportedSubjects(runCtx context.Context, ctx *Context) (map[string][]string, error) {
+	output, err := ExecuteCommand(runCtx, Confluent, ctx.withEnvironment([]string{"schema-registry", "exporter", "list", "-o", "json"}), false)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	if err = json.Unmarshal(output, &names); err != nil {
+		return nil, err
+	}
+	var exporters []SchemaExporter
+	for _, name := range names {
+		output, err = ExecuteCommand(runCtx, Confluent, ctx.withEnvironment([]string{"schema-registry", "exporter", "describe", name, "-o", "json"}), false)
+		if err != nil {
+			return nil, err
+		}
+		var exporter SchemaExporter
+		if err = json.Unmarshal(output, &exporter); err != nil {
+			return nil, err
+		}
+		exporters = append(exporters, exporter)
+	}
+	return exportedSubjects(exporters, ctx.Subjects), nil
+}
+
+// UnknownExports assumes every subject is exported, as schema exporters could not be listed.
+func UnknownExports(subjects []string) map[string][]string {
+	exported := make(map[string][]string)
+	for _, subject := range subjects {
+		exported[subject] = []string{UnknownExporters}
+	}
+	return exported
+}
+
+func exportedSubjects(exporters []SchemaExporter, subjects []string) map[string][]string {
+	exported := make(map[string][]string)
+	for _, exporter := range exporters {
+		for _, subject := range subjects {
+			if exportsSubject(exporter, subject) {
+				exported[subject] = append(exported[subject], exporter.Name)
+			}
+		}
+	}
+	return exported
+}
+
+// exportsSubject checks whether a subject matches the subject filters of an exporter, where "*" matches any
+// characters and ":*:" every context.
+func exportsSubject(exporter SchemaExporter, subject string) bool {
+	for _, filter := range exporter.Subjects {
+		if filter == ALL_CONTEXTS_PREFIX {
+			return true
+		}
+		if !strings.HasPrefix(filter, CONTEXT_PREFIX) && SubjectContext(subject) != DEFAULT_CONTEXT {
+			continue
+		}
+		pattern := "^" + strings.ReplaceAll(regexp.QuoteMeta(filter), `\*`, ".*") + "$"
+		if matched, _ := regexp.MatchString(pattern, subject); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// exporterClusters returns the destination clusters of the exporters of a subject, as mapped by the topology.
+func (ctx *Context) exporterClusters(subject string) []string {
+	var clusters []string
+	for _, exporter := range ctx.Exports[subject] {
+		clusters = append(clusters, ctx.Topology.exporterClusters(exporter)...)
+	}
+	return clusters
+}
+
+// unscannedExports returns the exporters of a subject whose destination clusters are not mapped by the topology.
+func (ctx *Context) unscannedExports(subject string) []string {
+	var exporters []string
+	for _, exporter := range ctx.Exports[subject] {
+		if len(ctx.Topology.exporterClusters(exporter)) == 0 {
+			exporters = append(exporters, exporter)
+		}
+	}
+	sort.Strings(exporters)
+	return exporters
+}
+
+// UnscannedExportedSubjects returns the subjects exported to another Schema Registry whose topics are not scanned.
+func UnscannedExportedSubjects(ctx *Context) []string {
+	var subjects []string
+	for _, subject := range ctx.Subjects {
+		if len(ctx.unscannedExports(subject)) > 0 {
+			subjects = append(subjects, subject)
+		}
+	}
+	return subjects
+}
+
+// ProtectExportedSchemas leaves out the schemas of subjects exported by schema exporters whose destination
+// clusters are not scanned, as topics of the destination environment may use them.
+func ProtectExportedSchemas(ctx *Context, schemas []SchemaInfo) []SchemaInfo {
+	var remaining []SchemaInfo
+	var exported []ExportedSchema
+	for _, schema := range schemas {
+		if exporters := ctx.unscannedExports(schema.Subject); len(exporters) > 0 {
+			exported = append(exported, ExportedSchema{schema.SchemaID, schema.Subject, schema.Version, strings.Join(exporters, ",")})
+		} else {
+			remaining = append(remaining, schema)
+		}
+	}
+	if len(exported) == 0 {
+		return schemas
+	}
+	fmt.Printf("%sExported%s: following %d schemas are protected as schema exporters copy their subject to another "+
+		"Schema Registry, whose topics are not scanned. Map the exporters to their destination clusters in the "+
+		"topology file to scan them.\n", RED, RESET, len(exported))
+	PrintTable(ExportedSchemaFields, exported, false)
+	return remaining
+}
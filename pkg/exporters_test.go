@@ -0,0 +1,50 @@
+package pkg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportedSubjects(t *testing.T) {
+	req := require.New(t)
+	exporters := []SchemaExporter{
+		{Name: "to-dr", Subjects: []string{"orders-*"}},
+		{Name: "to-analytics", Subjects: []string{"orders-value", ":.mycontext:*"}},
+		{Name: "everything", Subjects: []string{ALL_CONTEXTS_PREFIX}},
+	}
+	subjects := []string{"orders-key", "orders-value", "payments-value", ":.mycontext:users-value", ":.other:orders-key"}
+	exported := exportedSubjects(exporters[:2], subjects)
+	req.Equal(map[string][]string{
+		"orders-key":              {"to-dr"},
+		"orders-value":            {"to-dr", "to-analytics"},
+		":.mycontext:users-value": {"to-analytics"},
+	}, exported)
+	req.Len(exportedSubjects(exporters, subjects), len(subjects))
+
+	ctx := &Context{
+		Subjects: subjects,
+		Exports:  exported,
+		Topology: &Topology{Exporters: map[string][]string{"to-dr": {"lkc-dr"}}},
+	}
+	req.Equal([]string{"orders-value", ":.mycontext:users-value"}, UnscannedExportedSubjects(ctx))
+	req.Equal([]string{"lkc-dr"}, ctx.exporterClusters("orders-key"))
+
+	schemas := []SchemaInfo{
+		{SchemaID: "100001", Subject: "orders-key", Version: "1"},
+		{SchemaID: "100002", Subject: "orders-value", Version: "1"},
+		{SchemaID: "100003", Subject: "payments-value", Version: "1"},
+	}
+	req.Equal([]SchemaInfo{schemas[0], schemas[2]}, ProtectExportedSchemas(ctx, schemas))
+
+	// Subjects are protected when exporters cannot be listed, even with exporters mapped by the topology.
+	unknown := &Context{Subjects: subjects, Exports: UnknownExports(subjects), Topology: ctx.Topology}
+	req.Empty(ProtectExportedSchemas(unknown, schemas))
+	req.Equal(subjects, UnscannedExportedSubjects(unknown))
+
+	ctx.Clusters = []string{"lkc-123"}
+	ctx.Topics = []string{"orders", "payments", "users"}
+	clusters, topics := ctx.topicsByCluster()
+	req.Equal([]string{"lkc-dr", "lkc-123"}, clusters)
+	req.Equal([]string{"orders"}, topics["lkc-dr"].names)
+}
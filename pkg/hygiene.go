@@ -0,0 +1,95 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+var (
+	UnknownSchemaIDFields = []interface{}{"Topic", "ClusterID", "SchemaID", "UsedBy", "Messages", "SampleOffsets"}
+	MessageFramingFields  = []interface{}{"Topic", "ClusterID", "Messages", "UnframedKeys", "UnframedValues"}
+)
+
+// UnknownSchemaID is a schema ID found in a topic that is not registered in Schema Registry.
+type UnknownSchemaID struct {
+	Topic         string
+	ClusterID     string
+	SchemaID      int32
+	UsedBy        string
+	Messages      int64
+	SampleOffsets string
+}
+
+// MessageFraming is the share of the messages of a topic not framed in the Confluent wire format.
+type MessageFraming struct {
+	Topic          string
+	ClusterID      string
+	Messages       int64
+	UnframedKeys   string
+	UnframedValues string
+}
+
+// ReportDataHygiene reports the schema IDs found in the scanned topics that Schema Registry does not know of,
+// along with the share of messages without the magic byte.
+func ReportDataHygiene(runCtx context.Context, ctx *Context, schemas []SchemaInfo) error {
+	registeredSchemas, err := ctx.RegisteredSchemas(runCtx)
+	if err != nil {
+		return err
+	}
+	registered := make(map[int32]struct{})
+	for _, list := range [][]SchemaInfo{registeredSchemas, schemas} {
+		for _, schema := range list {
+			registered[schemaIDOf(schema)] = struct{}{}
+		}
+	}
+	unknown, framing := checkDataHygiene(ctx, registered)
+
+	if len(unknown) == 0 {
+		fmt.Println("All schema IDs found in the scanned topics are registered in Schema Registry.")
+	} else {
+		fmt.Printf("%sFollowing %d schema IDs found in the scanned topics are not registered in Schema Registry.%s "+
+			"The messages may have been produced against another Schema Registry, or are not framed in the "+
+			"Confluent wire format.\n", RED, len(unknown), RESET)
+		PrintTable(UnknownSchemaIDFields, unknown, false)
+	}
+	if len(framing) > 0 {
+		fmt.Println("Following topics have messages whose key or value does not start with the magic byte.")
+		PrintTable(MessageFramingFields, framing, false)
+	}
+	return nil
+}
+
+func checkDataHygiene(ctx *Context, registered map[int32]struct{}) ([]UnknownSchemaID, []MessageFraming) {
+	var unknown []UnknownSchemaID
+	var framing []MessageFraming
+	for _, scan := range ctx.ScannedTopics() {
+		var schemaIDs []int32
+		for schemaID := range scan.Usages {
+			if _, ok := registered[schemaID]; !ok {
+				schemaIDs = append(schemaIDs, schemaID)
+			}
+		}
+		sort.Slice(schemaIDs, func(i, j int) bool {
+			return schemaIDs[i] < schemaIDs[j]
+		})
+		for _, schemaID := range schemaIDs {
+			usage := scan.Usages[schemaID]
+			unknown = append(unknown, UnknownSchemaID{scan.Topic, scan.ClusterID, schemaID, usedBy(usage.Type),
+				usage.Messages, formatOffsetRanges(usage)})
+		}
+		if scan.Stats.UnframedKeys > 0 || scan.Stats.UnframedValues > 0 {
+			framing = append(framing, MessageFraming{scan.Topic, scan.ClusterID, scan.Stats.Messages,
+				formatShare(scan.Stats.UnframedKeys, scan.Stats.Messages),
+				formatShare(scan.Stats.UnframedValues, scan.Stats.Messages)})
+		}
+	}
+	return unknown, framing
+}
+
+func formatShare(count, total int64) string {
+	if total == 0 {
+		return "0"
+	}
+	return fmt.Sprintf("%d (%.1f%%)", count, float64(count)*100/float64(total))
+}
@@ -0,0 +1,37 @@
+package pkg
+
+import (
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageStats(t *testing.T) {
+	req := require.New(t)
+	var stats MessageStats
+	stats.record(&kafka.Message{Key: []byte("key"), Value: []byte{0, 0, 1, 134, 161, 'v'}})
+	stats.record(&kafka.Message{Key: []byte{0, 0, 1, 134, 161}, Value: []byte("{}")})
+	stats.record(&kafka.Message{Key: []byte("key")})
+	req.Equal(MessageStats{Messages: 3, UnframedKeys: 2, UnframedValues: 1}, stats)
+}
+
+func TestCheckDataHygiene(t *testing.T) {
+	req := require.New(t)
+	ctx := &Context{
+		Scans: []*TopicScan{
+			{TopicWithClusterInfo: TopicWithClusterInfo{"orders", "lkc-123"}, Status: SCANNED,
+				Stats: MessageStats{Messages: 8, UnframedValues: 2},
+				Usages: map[int32]*SchemaUsage{
+					100001: {Type: VALUEONLY, Messages: 5},
+					200002: {Type: VALUEONLY, Messages: 1, FirstOffsets: map[int32]kafka.Offset{0: 4},
+						LastValueOffsets: map[int32]kafka.Offset{0: 4}},
+				}},
+			{TopicWithClusterInfo: TopicWithClusterInfo{"payments", "lkc-123"}, Status: SCAN_FAILED,
+				Usages: map[int32]*SchemaUsage{300003: {Type: VALUEONLY, Messages: 1}}},
+		},
+	}
+	unknown, framing := checkDataHygiene(ctx, map[int32]struct{}{100001: {}})
+	req.Equal([]UnknownSchemaID{{"orders", "lkc-123", 200002, "value", 1, "0:4-4"}}, unknown)
+	req.Equal([]MessageFraming{{"orders", "lkc-123", 8, "0 (0.0%)", "2 (25.0%)"}}, framing)
+}
@@ -0,0 +1,177 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// UsageIndex is how schema IDs are used by every readable topic of the clusters of an environment.
+type UsageIndex struct {
+	Environment    string          `json:"environment"`
+	SchemaContext  string          `json:"schema_context"`
+	Clusters       []string        `json:"clusters"`
+	IsolationLevel string          `json:"isolation_level"`
+	CreatedAt      time.Time       `json:"created_at"`
+	Topics         []TopicProgress `json:"topics"`
+	// ActiveSchemas are the schema IDs found in use by the completely scanned topics.
+	ActiveSchemas map[int32]int `json:"active_schemas"`
+}
+
+// UsageIndexFile holds the usage index of each environment and schema context.
+type UsageIndexFile struct {
+	Indexes []UsageIndex `json:"indexes"`
+}
+
+func loadUsageIndexFile(path string) (*UsageIndexFile, error) {
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &UsageIndexFile{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file UsageIndexFile
+	if err = json.Unmarshal(content, &file); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+func (file *UsageIndexFile) index(environment, schemaContext string) *UsageIndex {
+	for i := range file.Indexes {
+		if file.Indexes[i].Environment == environment && file.Indexes[i].SchemaContext == schemaContext {
+			return &file.Indexes[i]
+		}
+	}
+	return nil
+}
+
+// SaveUsageIndex saves the usages found by scanning all topics, replacing the index of the same environment and
+// schema context.
+func SaveUsageIndex(ctx *Context) error {
+	file, err := loadUsageIndexFile(ctx.UsageIndexFile)
+	if err != nil {
+		return err
+	}
+	clusters, _ := ctx.topicsByCluster()
+	index := UsageIndex{
+		Environment:    ctx.Environment,
+		SchemaContext:  ctx.SchemaContext,
+		Clusters:       clusters,
+		IsolationLevel: ctx.IsolationLevel,
+		CreatedAt:      time.Now(),
+		ActiveSchemas:  make(map[int32]int),
+	}
+	for _, scan := range ctx.Scans {
+		index.Topics = append(index.Topics, TopicProgress{scan.Topic, scan.ClusterID, scan.Status, scan.Error,
+			scan.Endpoint, scan.HighWatermarks, nil, scan.Usages, scan.UncommittedOnly, scan.Stats})
+		if scan.Status == SCANNED {
+			for k, v := range usageTypes(scan.Usages) {
+				index.ActiveSchemas[k] = index.ActiveSchemas[k] | v
+			}
+		}
+	}
+	if existing := file.index(ctx.Environment, ctx.SchemaContext); existing != nil {
+		*existing = index
+	} else {
+		file.Indexes = append(file.Indexes, index)
+	}
+	content, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(ctx.UsageIndexFile), 0700); err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(ctx.UsageIndexFile, content, 0600); err != nil {
+		return err
+	}
+	fmt.Printf("Saved the usage index of %d topic(s) to %s.\n", len(index.Topics), ctx.UsageIndexFile)
+	return nil
+}
+
+// ReuseUsageIndex takes the usages of the topics from a saved usage index, and scans only the topics missing
+// from it or that failed to be scanned. It returns the schema IDs in use.
+func ReuseUsageIndex(runCtx context.Context, ctx *Context) (map[int32]int, error) {
+	file, err := loadUsageIndexFile(ctx.UsageIndexFile)
+	if err != nil {
+		return nil, err
+	}
+	index := file.index(ctx.Environment, ctx.SchemaContext)
+	if index == nil {
+		return nil, fmt.Errorf("no usage index of environment %s and schema context %s in %s, build one with "+
+			"--scan-all-topics", ctx.Environment, ctx.SchemaContext, ctx.UsageIndexFile)
+	}
+	if err = index.Validate(ctx, time.Now()); err != nil {
+		return nil, err
+	}
+	fmt.Printf("Reusing the usage index of %d topic(s) built on %s.\n", len(index.Topics), formatSeen(index.CreatedAt, time.Now()))
+	topics, err := listTopics(runCtx, ctx)
+	if err != nil {
+		return nil, err
+	}
+	missing, usedSchemas := index.restore(ctx, topics)
+	if len(missing) == 0 {
+		return usedSchemas, nil
+	}
+	fmt.Printf("Scanning %d topic(s) missing from the usage index...\n", len(missing))
+	newSchemas, err := scanTopics(runCtx, missing, ctx)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range newSchemas {
+		usedSchemas[k] = usedSchemas[k] | v
+	}
+	return usedSchemas, nil
+}
+
+// restore adds the scans of the indexed topics that are still listed to the context, and returns the listed topics
+// left to scan along with the schema IDs in use by the indexed ones.
+func (index *UsageIndex) restore(ctx *Context, topics []TopicWithClusterInfo) ([]TopicWithClusterInfo, map[int32]int) {
+	indexed := make(map[TopicWithClusterInfo]TopicProgress)
+	for _, topic := range index.Topics {
+		if topic.Status == SCANNED {
+			indexed[TopicWithClusterInfo{topic.Topic, topic.ClusterID}] = topic
+		}
+	}
+	var missing []TopicWithClusterInfo
+	usedSchemas := make(map[int32]int)
+	for _, topic := range topics {
+		progress, ok := indexed[topic]
+		if !ok {
+			missing = append(missing, topic)
+			continue
+		}
+		ctx.Scans = append(ctx.Scans, &TopicScan{TopicWithClusterInfo: topic, Endpoint: progress.Endpoint,
+			HighWatermarks: progress.HighWatermarks, Usages: progress.Usages, UncommittedOnly: progress.UncommittedOnly,
+			Stats: progress.Stats, Status: SCANNED})
+		for k, v := range usageTypes(progress.Usages) {
+			usedSchemas[k] = usedSchemas[k] | v
+		}
+	}
+	return missing, usedSchemas
+}
+
+// Validate makes sure the index is recent enough, covers every selected cluster and every cluster the topics of
+// the subjects are looked for in, and has been built with the same isolation level.
+func (index *UsageIndex) Validate(ctx *Context, now time.Time) error {
+	if ctx.UsageIndexMaxAge > 0 && now.Sub(index.CreatedAt) > ctx.UsageIndexMaxAge {
+		return fmt.Errorf("the usage index was built on %s, more than %s ago, build it again with --scan-all-topics",
+			formatSeen(index.CreatedAt, now), ctx.UsageIndexMaxAge)
+	}
+	clusters, _ := ctx.topicsByCluster()
+	for _, cluster := range append(clusters, ctx.Clusters...) {
+		if !ContainsTopic(cluster, index.Clusters) {
+			return fmt.Errorf("the usage index does not cover cluster %s, build it again with --scan-all-topics", cluster)
+		}
+	}
+	if index.IsolationLevel != ctx.IsolationLevel {
+		return fmt.Errorf("the usage index was built with isolation level %s, not %s", index.IsolationLevel, ctx.IsolationLevel)
+	}
+	return nil
+}
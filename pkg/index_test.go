@@ -0,0 +1,115 @@
+package pkg
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsageIndex(t *testing.T) {
+	req := require.New(t)
+	changelog := TopicWithClusterInfo{"orders-app-store-changelog", "lkc-123"}
+	payments := TopicWithClusterInfo{"payments", "lkc-123"}
+	ctx := &Context{
+		Environment:    "env-123",
+		SchemaContext:  DEFAULT_CONTEXT,
+		Clusters:       []string{"lkc-123"},
+		IsolationLevel: ReadCommitted,
+		ScanAllTopics:  true,
+		UsageIndexFile: filepath.Join(t.TempDir(), "index.json"),
+		Scans: []*TopicScan{
+			{TopicWithClusterInfo: changelog, Status: SCANNED, HighWatermarks: map[int32]kafka.Offset{0: 10},
+				Usages: map[int32]*SchemaUsage{100001: {Type: VALUEONLY, Messages: 3, ValueMessages: 3}}},
+			{TopicWithClusterInfo: payments, Status: SCAN_FAILED, Error: "denied"},
+		},
+	}
+	clusters, _ := ctx.topicsByCluster()
+	req.Equal([]string{"lkc-123"}, clusters)
+	req.NoError(SaveUsageIndex(ctx))
+	ctx.Environment = "env-456"
+	req.NoError(SaveUsageIndex(ctx))
+
+	file, err := loadUsageIndexFile(ctx.UsageIndexFile)
+	req.NoError(err)
+	req.Len(file.Indexes, 2)
+	req.Nil(file.index("env-123", ".mycontext"))
+	index := file.index("env-123", DEFAULT_CONTEXT)
+
+	reused := &Context{
+		Environment:      "env-123",
+		SchemaContext:    DEFAULT_CONTEXT,
+		Clusters:         []string{"lkc-123"},
+		Subjects:         []string{"com.acme.Order"},
+		IsolationLevel:   ReadCommitted,
+		UsageIndexFile:   ctx.UsageIndexFile,
+		UsageIndexMaxAge: time.Hour,
+	}
+	req.True(reused.AnyNamingStrategy())
+	req.NoError(index.Validate(reused, time.Now()))
+	req.Error(index.Validate(reused, time.Now().Add(2*time.Hour)))
+
+	// Topics created since the index was built, and topics that could not be scanned then, are left to scan.
+	created := TopicWithClusterInfo{"shipments", "lkc-123"}
+	missing, usedSchemas := index.restore(reused, []TopicWithClusterInfo{changelog, payments, created})
+	req.Equal([]TopicWithClusterInfo{payments, created}, missing)
+	req.Equal(map[int32]int{100001: VALUEONLY}, usedSchemas)
+	req.Len(reused.ScannedTopics(), 1)
+	req.True(isSchemaUsed(SchemaInfo{SchemaID: "100001", Subject: "com.acme.Order"}, usedSchemas))
+	req.False(isSchemaUsed(SchemaInfo{SchemaID: "100001", Subject: "orders-key"}, usedSchemas))
+
+	// A topic that cannot be scanned may use the schemas of any subject.
+	reused.Scans = append(reused.Scans, &TopicScan{TopicWithClusterInfo: payments, Status: SCAN_FAILED})
+	req.Equal([]string{"com.acme.Order"}, UnknownUsageSubjects(reused))
+	req.Empty(ProtectUnknownUsage(reused, []SchemaInfo{{SchemaID: "100009", Subject: "com.acme.Order", Version: "1"}}))
+
+	reused.Clusters = []string{"lkc-123", "lkc-456"}
+	req.Error(index.Validate(reused, time.Now()))
+	reused.Clusters = []string{"lkc-123"}
+	reused.IsolationLevel = ReadUncommitted
+	req.Error(index.Validate(reused, time.Now()))
+}
+
+func TestShareTopicScans(t *testing.T) {
+	req := require.New(t)
+	orders := TopicWithClusterInfo{"orders", "lkc-123"}
+	payments := TopicWithClusterInfo{"payments", "lkc-456"}
+	ctx := &Context{
+		Environment:     "env-123",
+		Clusters:        []string{"lkc-123", "lkc-456"},
+		ContextClusters: map[string][]string{".mycontext": {"lkc-123"}, DEFAULT_CONTEXT: {"lkc-456"}},
+		IsolationLevel:  ReadCommitted,
+		ScanAllTopics:   true,
+		UsageIndexFile:  filepath.Join(t.TempDir(), "index.json"),
+		TopicScans: []*TopicScan{
+			{TopicWithClusterInfo: orders, Status: SCANNED, HighWatermarks: map[int32]kafka.Offset{0: 10},
+				Usages: map[int32]*SchemaUsage{1: {Type: VALUEONLY, Messages: 3, ValueMessages: 3}}},
+			{TopicWithClusterInfo: TopicWithClusterInfo{ALL_TOPICS, "lkc-456"}, Status: SCAN_FAILED, Error: "denied"},
+			{TopicWithClusterInfo: payments, Status: SCANNED,
+				Usages: map[int32]*SchemaUsage{1: {Type: KEYONLY, Messages: 1, KeyMessages: 1}}},
+		},
+	}
+
+	// Each schema context takes the scans of the topics of its own clusters.
+	contextCtx := ctx.ForSchemaContext(".mycontext", []string{":.mycontext:orders-value"})
+	req.Equal(map[int32]int{1: VALUEONLY}, ShareTopicScans(contextCtx))
+	req.Len(contextCtx.Scans, 1)
+	req.NoError(SaveUsageIndex(contextCtx))
+	defaultCtx := ctx.ForSchemaContext(DEFAULT_CONTEXT, []string{"payments-key"})
+	req.Equal(map[int32]int{1: KEYONLY}, ShareTopicScans(defaultCtx))
+	req.Len(defaultCtx.Scans, 2)
+	req.NoError(SaveUsageIndex(defaultCtx))
+
+	// Rechecking a topic for a schema context leaves the scan of the environment unchanged.
+	contextCtx.Scans[0].Status = SCAN_FAILED
+	req.Equal(SCANNED, ctx.TopicScans[0].Status)
+
+	file, err := loadUsageIndexFile(ctx.UsageIndexFile)
+	req.NoError(err)
+	req.Len(file.Indexes, 2)
+	req.Equal([]string{"lkc-123"}, file.index("env-123", ".mycontext").Clusters)
+	req.Equal(map[int32]int{1: VALUEONLY}, file.index("env-123", ".mycontext").ActiveSchemas)
+	req.Equal(map[int32]int{1: KEYONLY}, file.index("env-123", DEFAULT_CONTEXT).ActiveSchemas)
+}
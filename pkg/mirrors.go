@@ -0,0 +1,136 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const MirrorActive = "ACTIVE"
+
+var ListedTopicFields = []interface{}{"Topic", "ClusterID", "Link", "Scan", "Note"}
+
+type ClusterLink struct {
+	Name            string `json:"name"`
+	SourceClusterID string `json:"source_cluster_id"`
+	RemoteClusterID string `json:"remote_cluster_id"`
+}
+
+type MirrorTopic struct {
+	LinkName        string `json:"link_name"`
+	MirrorTopicName string `json:"mirror_topic_name"`
+	SourceTopicName string `json:"source_topic_name"`
+	MirrorStatus    string `json:"mirror_status"`
+}
+
+// MirrorSource is the topic a mirror topic is mirrored from through a cluster link.
+type MirrorSource struct {
+	TopicWithClusterInfo
+	Link   string
+	Status string
+}
+
+// ListedTopic is an eligible topic, along with its cluster link relationship, whether it is scanned and how it was
+// found when not named after a subject.
+type ListedTopic struct {
+	Topic     string
+	ClusterID string
+	Link      string
+	Scan      string
+	Note      string
+}
+
+// selectMirrors leaves out the active mirror topics whose source topic is scanned too, unless all copies are
+// scanned. Mirrors that are no longer active may hold messages of their own and are always scanned.
+func selectMirrors(topics []TopicWithClusterInfo, mirrors map[TopicWithClusterInfo]MirrorSource, scanMirrors bool) ([]TopicWithClusterInfo, []ListedTopic) {
+	listed := make(map[TopicWithClusterInfo]bool)
+	for _, topic := range topics {
+		listed[topic] = true
+	}
+	mirroredTo := make(map[TopicWithClusterInfo][]string)
+	for mirror, source := range mirrors {
+		if listed[mirror] && listed[source.TopicWithClusterInfo] {
+			mirroredTo[source.TopicWithClusterInfo] = append(mirroredTo[source.TopicWithClusterInfo],
+				fmt.Sprintf("%s (link %s)", mirror.ClusterID, source.Link))
+		}
+	}
+	var selected []TopicWithClusterInfo
+	var listedTopics []ListedTopic
+	for _, topic := range topics {
+		entry := ListedTopic{Topic: topic.Topic, ClusterID: topic.ClusterID, Scan: "yes"}
+		if source, ok := mirrors[topic]; ok {
+			entry.Link = fmt.Sprintf("mirror of %s in %s (link %s, %s)", source.Topic, source.ClusterID, source.Link, source.Status)
+			if !scanMirrors && source.Status == MirrorActive && listed[source.TopicWithClusterInfo] {
+				entry.Scan = "no, source scanned"
+			}
+		} else if clusters, ok := mirroredTo[topic]; ok {
+			sort.Strings(clusters)
+			entry.Link = fmt.Sprintf("source of mirrors in %s", strings.Join(clusters, ", "))
+		}
+		if entry.Scan == "yes" {
+			selected = append(selected, topic)
+		}
+		listedTopics = append(listedTopics, entry)
+	}
+	return selected, listedTopics
+}
+
+// listMirrorTopics returns the mirror topics of the clusters along with their source. Clusters whose mirror
+// topics cannot be listed are reported, and all their topics are scanned.
+func listMirrorTopics(runCtx context.Context, ctx *Context, topics []TopicWithClusterInfo) map[TopicWithClusterInfo]MirrorSource {
+	mirrors := make(map[TopicWithClusterInfo]MirrorSource)
+	var clusters []string
+	for _, topic := range topics {
+		if !ContainsTopic(topic.ClusterID, clusters) && len(ctx.Topology.cluster(topic.ClusterID).BootstrapServers) == 0 {
+			clusters = append(clusters, topic.ClusterID)
+		}
+	}
+	for _, cluster := range clusters {
+		clusterMirrors, err := listClusterMirrorTopics(runCtx, ctx, cluster)
+		if err != nil {
+			fmt.Printf("%sFailed to list mirror topics of cluster %s, scanning all its topics: %v%s\n", RED, cluster, err, RESET)
+			continue
+		}
+		for topic, source := range clusterMirrors {
+			mirrors[TopicWithClusterInfo{topic, cluster}] = source
+		}
+	}
+	return mirrors
+}
+
+func listClusterMirrorTopics(runCtx context.Context, ctx *Context, cluster string) (map[string]MirrorSource, error) {
+	output, err := ExecuteCommand(runCtx, Confluent, ctx.withClusterEnvironment(cluster, []string{"kafka", "link", "list", "--cluster", cluster, "-o", "json"}), false)
+	if err != nil {
+		return nil, err
+	}
+	var links []ClusterLink
+	if err = json.Unmarshal(output, &links); err != nil {
+		return nil, err
+	}
+	sourceClusters := make(map[string]string)
+	for _, link := range links {
+		sourceClusters[link.Name] = link.SourceClusterID
+		if len(link.SourceClusterID) == 0 {
+			sourceClusters[link.Name] = link.RemoteClusterID
+		}
+	}
+	mirrors := make(map[string]MirrorSource)
+	if len(links) == 0 {
+		return mirrors, nil
+	}
+	output, err = ExecuteCommand(runCtx, Confluent, ctx.withClusterEnvironment(cluster, []string{"kafka", "mirror", "list", "--cluster", cluster, "-o", "json"}), false)
+	if err != nil {
+		return nil, err
+	}
+	var mirrorTopics []MirrorTopic
+	if err = json.Unmarshal(output, &mirrorTopics); err != nil {
+		return nil, err
+	}
+	for _, mirror := range mirrorTopics {
+		mirrors[mirror.MirrorTopicName] = MirrorSource{TopicWithClusterInfo{mirror.SourceTopicName, sourceClusters[mirror.LinkName]},
+			mirror.LinkName, mirror.MirrorStatus}
+	}
+	return mirrors, nil
+}
@@ -0,0 +1,31 @@
+package pkg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectMirrors(t *testing.T) {
+	req := require.New(t)
+	source := TopicWithClusterInfo{"orders", "lkc-123"}
+	mirror := TopicWithClusterInfo{"orders", "lkc-456"}
+	promoted := TopicWithClusterInfo{"orders", "lkc-789"}
+	orphan := TopicWithClusterInfo{"payments", "lkc-456"}
+	topics := []TopicWithClusterInfo{source, mirror, promoted, orphan}
+	mirrors := map[TopicWithClusterInfo]MirrorSource{
+		mirror:   {source, "link-1", MirrorActive},
+		promoted: {source, "link-2", "STOPPED"},
+		orphan:   {TopicWithClusterInfo{"payments", "lkc-000"}, "link-3", MirrorActive},
+	}
+
+	selected, listed := selectMirrors(topics, mirrors, false)
+	req.Equal([]TopicWithClusterInfo{source, promoted, orphan}, selected)
+	req.Equal("source of mirrors in lkc-456 (link link-1), lkc-789 (link link-2)", listed[0].Link)
+	req.Equal("mirror of orders in lkc-123 (link link-1, ACTIVE)", listed[1].Link)
+	req.Equal("no, source scanned", listed[1].Scan)
+	req.Equal("yes", listed[2].Scan)
+
+	selected, _ = selectMirrors(topics, mirrors, true)
+	req.Equal(topics, selected)
+}
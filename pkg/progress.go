@@ -0,0 +1,233 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+const (
+	StateDir          = ".schema-deletion-tool"
+	ProgressStateFile = "progress.json"
+)
+
+// CheckpointInterval is how often the progress of a topic scan is checkpointed while consuming it.
+var CheckpointInterval = 30 * time.Second
+
+// ScanProgress is what has been scanned so far. It is checkpointed while scanning so that an interrupted or
+// failed run can be resumed.
+type ScanProgress struct {
+	Environment    string          `json:"environment"`
+	Subjects       []string        `json:"subjects"`
+	Clusters       []string        `json:"clusters"`
+	IsolationLevel string          `json:"isolation_level"`
+	Topics         []TopicProgress `json:"topics"`
+	// ActiveSchemas are the schema IDs found in use by the completely scanned topics.
+	ActiveSchemas map[int32]int `json:"active_schemas,omitempty"`
+}
+
+type TopicProgress struct {
+	Topic          string                 `json:"topic"`
+	ClusterID      string                 `json:"cluster_id"`
+	Status         string                 `json:"status"`
+	Error          string                 `json:"error,omitempty"`
+	Endpoint       string                 `json:"endpoint,omitempty"`
+	HighWatermarks map[int32]kafka.Offset `json:"high_watermarks,omitempty"`
+	// Positions are the per-partition offsets to continue from when the topic has only been partially scanned.
+	Positions       map[int32]kafka.Offset `json:"positions,omitempty"`
+	Usages          map[int32]*SchemaUsage `json:"usages,omitempty"`
+	UncommittedOnly map[int32]int          `json:"uncommitted_only,omitempty"`
+	Stats           MessageStats           `json:"stats"`
+}
+
+// DefaultStateFile returns the path of a state file under the state directory in the home directory.
+func DefaultStateFile(name string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, StateDir, name), nil
+}
+
+func LoadProgress(path string) (*ScanProgress, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var progress ScanProgress
+	if err = json.Unmarshal(content, &progress); err != nil {
+		return nil, err
+	}
+	return &progress, nil
+}
+
+// Validate makes sure the progress has been saved by a run over the same environment, subjects and clusters with
+// the same isolation level, so that resuming from it does not mix up usages from different scans.
+func (progress *ScanProgress) Validate(ctx *Context) error {
+	if progress.Environment != ctx.Environment {
+		return fmt.Errorf("the scan to resume was run in environment %s, not %s", progress.Environment, ctx.Environment)
+	}
+	if !sameElements(progress.Subjects, ctx.Subjects) {
+		return fmt.Errorf("the scan to resume was run for subjects %v, not %v", progress.Subjects, ctx.Subjects)
+	}
+	if !sameElements(progress.Clusters, ctx.Clusters) {
+		return fmt.Errorf("the scan to resume was run over clusters %v, not %v", progress.Clusters, ctx.Clusters)
+	}
+	if progress.IsolationLevel != ctx.IsolationLevel {
+		return fmt.Errorf("the scan to resume was run with isolation level %s, not %s", progress.IsolationLevel, ctx.IsolationLevel)
+	}
+	return nil
+}
+
+// topic returns the saved progress of a topic, or nil if there is nothing to resume from.
+func (progress *ScanProgress) topic(topic TopicWithClusterInfo) *TopicProgress {
+	if progress == nil {
+		return nil
+	}
+	for i := range progress.Topics {
+		if progress.Topics[i].Topic == topic.Topic && progress.Topics[i].ClusterID == topic.ClusterID {
+			return &progress.Topics[i]
+		}
+	}
+	return nil
+}
+
+// clone returns a deep copy of the progress.
+func (progress *ScanProgress) clone() *ScanProgress {
+	if progress == nil {
+		return nil
+	}
+	clone := *progress
+	clone.Subjects = append([]string(nil), progress.Subjects...)
+	clone.Clusters = append([]string(nil), progress.Clusters...)
+	clone.ActiveSchemas = copyUsageTypes(progress.ActiveSchemas)
+	clone.Topics = make([]TopicProgress, len(progress.Topics))
+	for i, topic := range progress.Topics {
+		topic.HighWatermarks = copyOffsets(topic.HighWatermarks)
+		topic.Positions = copyOffsets(topic.Positions)
+		topic.UncommittedOnly = copyUsageTypes(topic.UncommittedOnly)
+		usages := topic.Usages
+		topic.Usages = make(map[int32]*SchemaUsage)
+		for schemaID, usage := range usages {
+			topic.Usages[schemaID] = usage.clone()
+		}
+		clone.Topics[i] = topic
+	}
+	return &clone
+}
+
+func copyOffsets(offsets map[int32]kafka.Offset) map[int32]kafka.Offset {
+	if offsets == nil {
+		return nil
+	}
+	copied := make(map[int32]kafka.Offset)
+	for partition, offset := range offsets {
+		copied[partition] = offset
+	}
+	return copied
+}
+
+func copyUsageTypes(usageTypes map[int32]int) map[int32]int {
+	if usageTypes == nil {
+		return nil
+	}
+	copied := make(map[int32]int)
+	for schemaID, usageType := range usageTypes {
+		copied[schemaID] = usageType
+	}
+	return copied
+}
+
+func sameElements(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sortedA := append([]string(nil), a...)
+	sortedB := append([]string(nil), b...)
+	sort.Strings(sortedA)
+	sort.Strings(sortedB)
+	for i := range sortedA {
+		if sortedA[i] != sortedB[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func SaveProgress(ctx *Context) error {
+	progress := ScanProgress{
+		Environment:    ctx.Environment,
+		Subjects:       ctx.Subjects,
+		Clusters:       ctx.Clusters,
+		IsolationLevel: ctx.IsolationLevel,
+		ActiveSchemas:  make(map[int32]int),
+	}
+	for _, scan := range ctx.Scans {
+		progress.Topics = append(progress.Topics, TopicProgress{scan.Topic, scan.ClusterID, scan.Status, scan.Error,
+			scan.Endpoint, scan.HighWatermarks, scan.Positions, scan.Usages, scan.UncommittedOnly, scan.Stats})
+		if scan.Status == SCANNED {
+			for k, v := range usageTypes(scan.Usages) {
+				progress.ActiveSchemas[k] = progress.ActiveSchemas[k] | v
+			}
+		}
+	}
+	content, err := json.MarshalIndent(progress, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(ctx.ProgressFile), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(ctx.ProgressFile, content, 0600)
+}
+
+// checkpoint saves the progress made so far, warning instead of failing so that a long scan is not stopped
+// because its progress could not be saved.
+func checkpoint(ctx *Context) {
+	if err := SaveProgress(ctx); err != nil {
+		fmt.Printf("%sFailed to checkpoint scan progress to %s: %v%s\n", RED, ctx.ProgressFile, err, RESET)
+	}
+}
+
+// ReportPartialScan prints what has been scanned before the run was interrupted, along with the schema IDs
+// found in use so far.
+func ReportPartialScan(ctx *Context, totalTopics int) {
+	scanned := ctx.ScannedTopics()
+	fmt.Printf("%sScan stopped after scanning %d of %d topic(s).%s\n", RED, len(scanned), totalTopics, RESET)
+	PrintTable(TopicScanFields, ctx.Scans, false)
+
+	activeSchemas := make(map[int32]int)
+	for _, scan := range scanned {
+		for k, v := range usageTypes(scan.Usages) {
+			activeSchemas[k] = activeSchemas[k] | v
+		}
+	}
+	fmt.Printf("Found %d schema ID(s) in use so far.\n", len(activeSchemas))
+	PrintTable(SchemaIDUsageFields, summarizeUsages(activeSchemas), false)
+}
+
+func summarizeUsages(activeSchemas map[int32]int) []SchemaIDUsage {
+	var usages []SchemaIDUsage
+	for schemaID, usageType := range activeSchemas {
+		usages = append(usages, SchemaIDUsage{schemaID, usedBy(usageType)})
+	}
+	sort.Slice(usages, func(i, j int) bool {
+		return usages[i].SchemaID < usages[j].SchemaID
+	})
+	return usages
+}
+
+func usedBy(usageType int) string {
+	if usageType == KEYONLY {
+		return "key"
+	} else if usageType == VALUEONLY {
+		return "value"
+	}
+	return "key, value"
+}
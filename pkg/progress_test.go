@@ -0,0 +1,88 @@
+package pkg
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResumeProgress(t *testing.T) {
+	req := require.New(t)
+	ctx := &Context{
+		Environment:    "env-123",
+		Subjects:       []string{"orders-value", "payments-value"},
+		Clusters:       []string{"lkc-123", "lkc-456"},
+		IsolationLevel: ReadCommitted,
+		ProgressFile:   filepath.Join(t.TempDir(), ProgressStateFile),
+		Scans: []*TopicScan{
+			{TopicWithClusterInfo: TopicWithClusterInfo{"orders", "lkc-123"}, Status: SCANNED,
+				HighWatermarks: map[int32]kafka.Offset{0: 10},
+				Usages:         map[int32]*SchemaUsage{100001: {Type: VALUEONLY, LastValueOffsets: map[int32]kafka.Offset{0: 9}}}},
+			{TopicWithClusterInfo: TopicWithClusterInfo{"payments", "lkc-456"}, Status: SCAN_INTERRUPTED,
+				HighWatermarks: map[int32]kafka.Offset{0: 10, 1: 20}, Positions: map[int32]kafka.Offset{0: 10, 1: 5}},
+		},
+	}
+	req.NoError(SaveProgress(ctx))
+
+	progress, err := LoadProgress(ctx.ProgressFile)
+	req.NoError(err)
+	req.Equal(map[int32]int{100001: VALUEONLY}, progress.ActiveSchemas)
+	req.Equal(kafka.Offset(9), progress.topic(TopicWithClusterInfo{"orders", "lkc-123"}).Usages[100001].LastValueOffsets[0])
+	req.Equal(map[int32]kafka.Offset{0: 10, 1: 5}, progress.topic(TopicWithClusterInfo{"payments", "lkc-456"}).Positions)
+	req.Nil(progress.topic(TopicWithClusterInfo{"orders", "lkc-456"}))
+
+	resumed := &Context{
+		Environment:    "env-123",
+		Subjects:       []string{"payments-value", "orders-value"},
+		Clusters:       []string{"lkc-456", "lkc-123"},
+		IsolationLevel: ReadCommitted,
+	}
+	req.NoError(progress.Validate(resumed))
+	resumed.Clusters = []string{"lkc-123"}
+	req.Error(progress.Validate(resumed))
+	resumed.Clusters = ctx.Clusters
+	resumed.IsolationLevel = ReadUncommitted
+	req.Error(progress.Validate(resumed))
+	resumed.IsolationLevel = ReadCommitted
+	resumed.Environment = "env-456"
+	req.Error(progress.Validate(resumed))
+	resumed.Environment = ctx.Environment
+
+	resumed.Subjects = []string{"orders-value", "payments-value", "users-value"}
+	req.Error(progress.Validate(resumed))
+	resumed.Subjects = []string{"orders-value"}
+	req.Error(progress.Validate(resumed))
+}
+
+func TestMergeUsages(t *testing.T) {
+	req := require.New(t)
+	previous := map[int32]*SchemaUsage{
+		100001: {Type: KEYONLY, Messages: 2, KeyMessages: 2, FirstOffsets: map[int32]kafka.Offset{0: 1},
+			LastKeyOffsets: map[int32]kafka.Offset{0: 3}, LastSeen: time.Unix(100, 0), LastKeySeen: time.Unix(100, 0)},
+	}
+	current := map[int32]*SchemaUsage{
+		100001: {Type: VALUEONLY, Messages: 1, ValueMessages: 1, FirstOffsets: map[int32]kafka.Offset{0: 7},
+			LastValueOffsets: map[int32]kafka.Offset{0: 7}, FirstSeen: time.Unix(200, 0), LastSeen: time.Unix(200, 0),
+			LastValueSeen: time.Unix(200, 0)},
+		100002: {Type: VALUEONLY, Messages: 1, ValueMessages: 1, FirstOffsets: map[int32]kafka.Offset{1: 2},
+			LastValueOffsets: map[int32]kafka.Offset{1: 2}},
+	}
+	merged := mergeUsages(previous, current)
+	req.Equal(map[int32]int{100001: KEYVALUE, 100002: VALUEONLY}, usageTypes(merged))
+	req.Equal(kafka.Offset(3), merged[100001].LastKeyOffsets[0])
+	req.Equal(kafka.Offset(7), merged[100001].LastValueOffsets[0])
+	req.Equal(int64(3), merged[100001].Messages)
+	req.Equal(int64(2), merged[100001].KeyMessages)
+	req.Equal(int64(1), merged[100001].ValueMessages)
+	req.Equal(map[int32]kafka.Offset{0: 1}, merged[100001].FirstOffsets)
+	req.Equal(map[int32]kafka.Offset{0: 7}, merged[100001].LastOffsets())
+	req.Equal(time.Unix(100, 0), merged[100001].FirstSeen)
+	req.Equal(time.Unix(200, 0), merged[100001].LastSeen)
+	req.Equal(time.Unix(100, 0), merged[100001].LastKeySeen)
+	req.Equal(time.Unix(200, 0), merged[100001].LastValueSeen)
+	req.Equal(KEYONLY, previous[100001].Type)
+	req.Empty(mergeUsages(nil, nil))
+}
@@ -0,0 +1,269 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const QuarantineStateFile = "quarantine.json"
+
+// QuarantineEntry records a schema version soft deleted during phase one of a two-phase delete.
+type QuarantineEntry struct {
+	Environment   string      `json:"environment"`
+	SchemaID      json.Number `json:"id"`
+	Subject       string      `json:"subject"`
+	Version       json.Number `json:"version"`
+	QuarantinedAt time.Time   `json:"quarantined_at"`
+}
+
+// QuarantineStore is the local state store keeping track of quarantined schema versions between runs.
+type QuarantineStore struct {
+	Path    string            `json:"-"`
+	Entries []QuarantineEntry `json:"entries"`
+}
+
+func LoadQuarantineStore(path string) (*QuarantineStore, error) {
+	store := &QuarantineStore{Path: path}
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(content, store); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func (store *QuarantineStore) Save() error {
+	content, err := json.MarshalIndent(store, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(store.Path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(store.Path, content, 0600)
+}
+
+// SetDefaultEnvironment assigns the entries recorded before environments were tracked to the given environment.
+func (store *QuarantineStore) SetDefaultEnvironment(environment string) {
+	for i := range store.Entries {
+		if len(store.Entries[i].Environment) == 0 {
+			store.Entries[i].Environment = environment
+		}
+	}
+}
+
+// Add records the schemas of an environment as quarantined at the given time, replacing any previous entry of
+// the same version.
+func (store *QuarantineStore) Add(environment string, schemas []SchemaInfo, at time.Time) {
+	store.Remove(environment, schemas)
+	for _, schema := range schemas {
+		store.Entries = append(store.Entries, QuarantineEntry{environment, schema.SchemaID, schema.Subject, schema.Version, at})
+	}
+}
+
+func (store *QuarantineStore) Remove(environment string, schemas []SchemaInfo) {
+	var entries []QuarantineEntry
+	for _, entry := range store.Entries {
+		if entry.Environment != environment || !containsVersion(schemas, entry.Subject, entry.Version) {
+			entries = append(entries, entry)
+		}
+	}
+	store.Entries = entries
+}
+
+func (store *QuarantineStore) quarantine(environment string, schemas []SchemaInfo) error {
+	store.Add(environment, schemas, time.Now())
+	return store.Save()
+}
+
+// Due returns the entries whose quarantine period has elapsed by the given time.
+func (store *QuarantineStore) Due(period time.Duration, now time.Time) []QuarantineEntry {
+	var due []QuarantineEntry
+	for _, entry := range store.Entries {
+		if !entry.QuarantinedAt.Add(period).After(now) {
+			due = append(due, entry)
+		}
+	}
+	return due
+}
+
+// DueInEnvironment returns the entries of an environment.
+func DueInEnvironment(entries []QuarantineEntry, environment string) []QuarantineEntry {
+	var due []QuarantineEntry
+	for _, entry := range entries {
+		if entry.Environment == environment {
+			due = append(due, entry)
+		}
+	}
+	return due
+}
+
+// DueInSubjects returns the entries of the given subjects in an environment.
+func DueInSubjects(entries []QuarantineEntry, environment string, subjects []string) []QuarantineEntry {
+	var due []QuarantineEntry
+	for _, entry := range entries {
+		if entry.Environment == environment && ContainsTopic(entry.Subject, subjects) {
+			due = append(due, entry)
+		}
+	}
+	return due
+}
+
+func QuarantinedSubjects(entries []QuarantineEntry) []string {
+	var subjects []string
+	for _, entry := range entries {
+		if !ContainsTopic(entry.Subject, subjects) {
+			subjects = append(subjects, entry.Subject)
+		}
+	}
+	return subjects
+}
+
+// QuarantineSchemas is phase one of a two-phase delete: it soft deletes the selected schemas and records them
+// in the state store, leaving the hard deletion to a later finalize run.
+func QuarantineSchemas(runCtx context.Context, ctx *Context, selection, schemas []SchemaInfo, store *QuarantineStore) (DeletionOutcome, error) {
+	quarantined, err := quarantineSchemas(runCtx, ctx, selection, schemas, store)
+	outcome := DeletionOutcome{Quarantined: newDeletions(quarantined, schemas)}
+	if err != nil {
+		return outcome, err
+	}
+	fmt.Printf("Quarantined a total of %d schemas in %s. Run again with --finalize after the quarantine period "+
+		"to hard delete them.\n", len(selection), store.Path)
+	return outcome, nil
+}
+
+// quarantineSchemas soft deletes the selected schemas and returns the ones quarantined, even on failure. Schemas
+// are recorded as soon as they are soft deleted, so that none is left out of the store on failure.
+func quarantineSchemas(runCtx context.Context, ctx *Context, selection, schemas []SchemaInfo, store *QuarantineStore) ([]SchemaInfo, error) {
+	var quarantined []SchemaInfo
+	subjects, versions := splitSubjectDeletions(selection, schemas)
+	for _, subject := range subjects {
+		if hasActiveVersions(subject, schemas) {
+			if err := deleteSubject(runCtx, ctx, subject, false); err != nil {
+				return quarantined, err
+			}
+		}
+		subjectSchemas := subjectVersions(selection, subject)
+		if err := store.quarantine(ctx.Environment, subjectSchemas); err != nil {
+			return quarantined, err
+		}
+		quarantined = append(quarantined, subjectSchemas...)
+	}
+	for _, schema := range versions {
+		if schema.State != SOFT_DELETED {
+			if err := deleteSchemaVersion(runCtx, ctx, schema, false); err != nil {
+				return quarantined, err
+			}
+		}
+		if err := store.quarantine(ctx.Environment, []SchemaInfo{schema}); err != nil {
+			return quarantined, err
+		}
+		quarantined = append(quarantined, schema)
+	}
+	return quarantined, nil
+}
+
+// FinalizeQuarantine is phase two of a two-phase delete: it hard deletes the due entries that are still soft
+// deleted and have not reappeared in any scanned topic since they were quarantined.
+func FinalizeQuarantine(runCtx context.Context, ctx *Context, store *QuarantineStore, due []QuarantineEntry, schemas []SchemaInfo, usedSchemas map[int32]int) (DeletionOutcome, error) {
+	var outcome DeletionOutcome
+	var selection, reappeared, stale, unknown []SchemaInfo
+	// Subjects exported to another Schema Registry whose topics are not scanned are kept in quarantine too.
+	unknownSubjects := append(UnknownUsageSubjects(ctx), UnscannedExportedSubjects(ctx)...)
+	for _, entry := range due {
+		if ContainsTopic(entry.Subject, unknownSubjects) {
+			// Kept in quarantine until the topics of the subject can be fully scanned.
+			unknown = append(unknown, SchemaInfo{SchemaID: entry.SchemaID, Subject: entry.Subject, Version: entry.Version})
+			continue
+		}
+		schema, ok := findVersion(schemas, entry.Subject, entry.Version)
+		if !ok || schema.State != SOFT_DELETED || schema.SchemaID != entry.SchemaID {
+			// The version has been hard deleted or registered again since it was quarantined.
+			stale = append(stale, SchemaInfo{SchemaID: entry.SchemaID, Subject: entry.Subject, Version: entry.Version})
+			continue
+		}
+		if isSchemaUsed(schema, usedSchemas) {
+			reappeared = append(reappeared, schema)
+			continue
+		}
+		selection = append(selection, schema)
+	}
+
+	if len(stale) > 0 {
+		fmt.Printf("Following %d quarantined schemas are no longer soft deleted and are dropped from quarantine.\n", len(stale))
+		PrintTable(SchemaInfoFields, stale, false)
+		store.Remove(ctx.Environment, stale)
+	}
+	if len(unknown) > 0 {
+		fmt.Printf("%sUsage unknown%s: following %d quarantined schemas stay in quarantine as their topics could not "+
+			"be fully scanned.\n", RED, RESET, len(unknown))
+		PrintTable(SchemaInfoFields, unknown, false)
+	}
+	if len(reappeared) > 0 {
+		fmt.Printf("%sFollowing %d quarantined schemas reappeared in topics and will not be hard deleted.%s "+
+			"Register them again to restore them.\n", RED, len(reappeared), RESET)
+		PrintTable(SchemaInfoFields, reappeared, false)
+		store.Remove(ctx.Environment, reappeared)
+	}
+	if len(selection) == 0 {
+		fmt.Println("No quarantined schemas are ready for hard deletion.")
+		return outcome, store.Save()
+	}
+
+	fmt.Printf("Following %d quarantined schemas are still unused after the quarantine period.\n", len(selection))
+	PrintTable(SchemaInfoFields, selection, false)
+	confirmed, err := confirmHardDeletion()
+	if err != nil {
+		return outcome, err
+	}
+	outcome.Declined = !confirmed
+	if confirmed {
+		if selection, err = RecheckTopics(runCtx, ctx, selection); err != nil {
+			return outcome, err
+		}
+		subjects, versions := splitSubjectDeletions(selection, schemas)
+		cleared, err := hardDeleteSchemas(runCtx, ctx, subjects, versions)
+		if err != nil {
+			return outcome, err
+		}
+		outcome.HardDeleted = newDeletions(selection, schemas)
+		store.Remove(ctx.Environment, selection)
+		fmt.Printf("Cleaned up a total of %d schemas: %d version(s) deleted, %d subject(s) removed, "+
+			"config and mode cleared for %d subject(s).\n", len(selection), len(versions), len(subjects), cleared)
+	}
+	return outcome, store.Save()
+}
+
+func containsVersion(schemas []SchemaInfo, subject string, version json.Number) bool {
+	_, ok := findVersion(schemas, subject, version)
+	return ok
+}
+
+func findVersion(schemas []SchemaInfo, subject string, version json.Number) (SchemaInfo, bool) {
+	for _, schema := range schemas {
+		if schema.Subject == subject && schema.Version == version {
+			return schema, true
+		}
+	}
+	return SchemaInfo{}, false
+}
+
+func subjectVersions(schemas []SchemaInfo, subject string) []SchemaInfo {
+	var versions []SchemaInfo
+	for _, schema := range schemas {
+		if schema.Subject == subject {
+			versions = append(versions, schema)
+		}
+	}
+	return versions
+}
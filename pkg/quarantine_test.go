@@ -0,0 +1,93 @@
+package pkg
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuarantineStore(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "quarantine")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+
+	now := time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)
+	store, err := LoadQuarantineStore(filepath.Join(dir, "state", QuarantineStateFile))
+	req.NoError(err)
+	req.Empty(store.Entries)
+
+	store.Add("env-123", []SchemaInfo{
+		{SchemaID: "100001", Subject: "orders-value", Version: "1"},
+		{SchemaID: "100002", Subject: "orders-value", Version: "2"},
+	}, now.Add(-48*time.Hour))
+	store.Add("env-123", []SchemaInfo{{SchemaID: "100003", Subject: "payments-value", Version: "1"}}, now)
+	req.NoError(store.Save())
+
+	loaded, err := LoadQuarantineStore(store.Path)
+	req.NoError(err)
+	req.Len(loaded.Entries, 3)
+
+	due := loaded.Due(24*time.Hour, now)
+	req.Len(due, 2)
+	req.Equal([]string{"orders-value"}, QuarantinedSubjects(due))
+	req.Len(loaded.Due(0, now), 3)
+
+	loaded.Remove("env-123", []SchemaInfo{{SchemaID: "100001", Subject: "orders-value", Version: "1"}})
+	req.Len(loaded.Entries, 2)
+	req.Equal("orders-value", loaded.Entries[0].Subject)
+	req.Equal("2", loaded.Entries[0].Version.String())
+}
+
+func TestQuarantineEnvironments(t *testing.T) {
+	req := require.New(t)
+	now := time.Now()
+	store := &QuarantineStore{Entries: []QuarantineEntry{
+		{SchemaID: "100001", Subject: "orders-value", Version: "1", QuarantinedAt: now},
+	}}
+	store.SetDefaultEnvironment("env-123")
+	req.Equal("env-123", store.Entries[0].Environment)
+
+	orders := []SchemaInfo{{SchemaID: "100001", Subject: "orders-value", Version: "1"}}
+	store.Add("env-456", orders, now)
+	req.Len(store.Entries, 2)
+	req.Len(DueInSubjects(store.Entries, "env-456", []string{"orders-value"}), 1)
+	req.Empty(DueInSubjects(store.Entries, "env-456", []string{"payments-value"}))
+
+	// The same version in another environment stays quarantined.
+	store.Remove("env-123", orders)
+	req.Equal([]QuarantineEntry{{"env-456", "100001", "orders-value", "1", now}}, store.Entries)
+}
+
+func TestQuarantineSchemasSavesSoftDeletedOnFailure(t *testing.T) {
+	req := require.New(t)
+	// Soft deleting version 2 fails.
+	commands := fakeConfluent(t, `case "$*" in *"--version 2 "*) exit 1;; esac`)
+	dir, err := ioutil.TempDir("", "quarantine")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+
+	schemas := []SchemaInfo{
+		{SchemaID: "100001", Subject: "orders-value", Version: "1", State: SOFT_DELETED},
+		{SchemaID: "100002", Subject: "orders-value", Version: "2", State: ACTIVE},
+		{SchemaID: "100003", Subject: "orders-value", Version: "3", State: ACTIVE},
+	}
+	store := &QuarantineStore{Path: filepath.Join(dir, QuarantineStateFile)}
+	ctx := &Context{Environment: "env-123"}
+	outcome, err := QuarantineSchemas(context.Background(), ctx, schemas[:2], schemas, store)
+	req.Error(err)
+	req.Equal(Deletions{1, 0}, outcome.Quarantined)
+
+	// The version soft deleted before the failure is saved, the one that failed is not.
+	loaded, err := LoadQuarantineStore(store.Path)
+	req.NoError(err)
+	req.Len(loaded.Entries, 1)
+	req.Equal("1", loaded.Entries[0].Version.String())
+	// Version 1 was already soft deleted and is quarantined as is.
+	req.Equal([]string{"schema-registry schema delete --subject orders-value --version 2 --force --environment env-123"}, commands())
+}
@@ -0,0 +1,101 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+const (
+	RecheckDrop  = "drop"
+	RecheckAbort = "abort"
+)
+
+// RecheckTopics drops (or aborts on) the selected schemas used by messages produced since the scan. The schemas
+// of the subjects of a topic that cannot be rechecked are dropped as usage unknown.
+func RecheckTopics(runCtx context.Context, ctx *Context, selection []SchemaInfo) ([]SchemaInfo, error) {
+	if len(selection) == 0 {
+		return selection, nil
+	}
+	fmt.Println("Re-checking messages produced to the scanned topics since the scan...")
+	newSchemas := make(map[int32]int)
+	var results []RecheckResult
+	var failed bool
+	for _, scan := range ctx.ScannedTopics() {
+		_newSchemas, newMessages, highWatermarks, err := recheckTopic(runCtx, ctx, scan)
+		if err != nil {
+			if runCtx.Err() != nil {
+				return nil, runCtx.Err()
+			}
+			fmt.Printf("%sFailed to recheck topic %s in cluster %s: %v%s\n", RED, scan.Topic, scan.ClusterID, err, RESET)
+			scan.Status = SCAN_FAILED
+			scan.Error = err.Error()
+			failed = true
+			continue
+		}
+		scan.HighWatermarks = highWatermarks
+		for k, v := range _newSchemas {
+			newSchemas[k] = newSchemas[k] | v
+		}
+		results = append(results, RecheckResult{scan.Topic, scan.ClusterID, newMessages, usedSchemaIDs(selection, _newSchemas)})
+	}
+	PrintTable(RecheckFields, results, false)
+	if failed {
+		selection = ProtectUnknownUsage(ctx, selection)
+	}
+
+	var remaining, found []SchemaInfo
+	for _, schema := range selection {
+		if isSchemaUsed(schema, newSchemas) {
+			found = append(found, schema)
+		} else {
+			remaining = append(remaining, schema)
+		}
+	}
+	if len(found) == 0 {
+		fmt.Println("None of the selected schemas has been used since the scan.")
+		return remaining, nil
+	}
+
+	fmt.Printf("%sFollowing %d selected schemas have been used since the scan.%s\n", RED, len(found), RESET)
+	PrintTable(SchemaInfoFields, found, false)
+	if ctx.RecheckPolicy == RecheckAbort {
+		return nil, errors.New("aborting deletion as selected schemas have been used since the scan")
+	}
+	fmt.Printf("Dropped them from the selection, %d schemas remain selected.\n", len(remaining))
+	return remaining, nil
+}
+
+func recheckTopic(runCtx context.Context, ctx *Context, scan *TopicScan) (map[int32]int, int64, map[int32]kafka.Offset, error) {
+	consumer, err := CreateConsumer(scan.Endpoint, ctx.Credentials[scan.ClusterID], ctx.IsolationLevel)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	defer CloseConsumer(consumer)
+	return scanNewMessages(runCtx, consumer, scan.Topic, scan.HighWatermarks)
+}
+
+// usedSchemaIDs lists the IDs of the given schemas that are in use, as a comma separated string.
+func usedSchemaIDs(schemas []SchemaInfo, usedSchemas map[int32]int) string {
+	var ids []int
+	seen := make(map[int]struct{})
+	for _, schema := range schemas {
+		id, _ := strconv.Atoi(string(schema.SchemaID))
+		if _, ok := seen[id]; ok || !isSchemaUsed(schema, usedSchemas) {
+			continue
+		}
+		seen[id] = struct{}{}
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	var strs []string
+	for _, id := range ids {
+		strs = append(strs, strconv.Itoa(id))
+	}
+	return strings.Join(strs, ",")
+}
@@ -0,0 +1,31 @@
+package pkg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecheckFailureProtectsTopicSubjects(t *testing.T) {
+	req := require.New(t)
+	requireConsumersClosed(t)
+	ctx := &Context{
+		Subjects: []string{"orders-value", "payments-value"},
+		Topics:   []string{"orders", "payments"},
+		// An invalid isolation level keeps the consumers from being created.
+		IsolationLevel: "invalid",
+		Scans: []*TopicScan{
+			{TopicWithClusterInfo: TopicWithClusterInfo{"orders", "lkc-123"}, Status: SCANNED},
+		},
+	}
+	selection := []SchemaInfo{
+		{SchemaID: "100001", Subject: "orders-value", Version: "1"},
+		{SchemaID: "100002", Subject: "payments-value", Version: "1"},
+	}
+	remaining, err := RecheckTopics(context.Background(), ctx, selection)
+	req.NoError(err)
+	req.Equal(selection[1:], remaining)
+	req.Equal(SCAN_FAILED, ctx.Scans[0].Status)
+	req.NotEmpty(ctx.Scans[0].Error)
+}
@@ -0,0 +1,88 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var SharedSchemaIDFields = []interface{}{"SchemaID", "Subjects", "Topics", "OtherSubjectTopics"}
+
+// SharedSchemaID tells which subjects hold a schema ID and which scanned topics use it.
+type SharedSchemaID struct {
+	SchemaID json.Number
+	Subjects string
+	Topics   string
+	// OtherSubjectTopics are the topics using the ID that do not belong to any of the cleaned up subjects
+	// holding it, and that keep it in use for those subjects.
+	OtherSubjectTopics string
+}
+
+// ReportSharedSchemaIDs reports the schema IDs of the cleaned up subjects that are registered under several
+// subjects, or used in topics of other subjects.
+func ReportSharedSchemaIDs(runCtx context.Context, ctx *Context, schemas []SchemaInfo) error {
+	registeredSchemas, err := ctx.RegisteredSchemas(runCtx)
+	if err != nil {
+		return err
+	}
+	shared := sharedSchemaIDs(ctx, schemas, registeredSchemas)
+	if len(shared) == 0 {
+		return nil
+	}
+	fmt.Printf("Following %d schema IDs are shared between subjects. A version using one of them is in use if any "+
+		"topic uses the ID, even a topic of another subject. Deleting the version only removes it from its "+
+		"subject: the ID stays registered as long as another subject still holds it.\n", len(shared))
+	PrintTable(SharedSchemaIDFields, shared, false)
+	return nil
+}
+
+func sharedSchemaIDs(ctx *Context, schemas, registeredSchemas []SchemaInfo) []SharedSchemaID {
+	subjectsByID := make(map[int32][]string)
+	for _, list := range [][]SchemaInfo{registeredSchemas, schemas} {
+		for _, schema := range list {
+			schemaID := schemaIDOf(schema)
+			if !ContainsTopic(schema.Subject, subjectsByID[schemaID]) {
+				subjectsByID[schemaID] = append(subjectsByID[schemaID], schema.Subject)
+			}
+		}
+	}
+	// The topics of the cleaned up subjects holding each ID.
+	ownTopics := make(map[int32][]string)
+	var schemaIDs []int32
+	for _, schema := range schemas {
+		schemaID := schemaIDOf(schema)
+		if _, ok := ownTopics[schemaID]; !ok {
+			schemaIDs = append(schemaIDs, schemaID)
+		}
+		ownTopics[schemaID] = append(ownTopics[schemaID], ctx.TopicsOf([]string{schema.Subject})...)
+	}
+	sort.Slice(schemaIDs, func(i, j int) bool {
+		return schemaIDs[i] < schemaIDs[j]
+	})
+
+	var shared []SharedSchemaID
+	for _, schemaID := range schemaIDs {
+		var topics, otherTopics []string
+		for _, scan := range ctx.ScannedTopics() {
+			usage, ok := scan.Usages[schemaID]
+			if !ok {
+				continue
+			}
+			topic := fmt.Sprintf("%s (%s)", scan.Topic, usedBy(usage.Type))
+			topics = append(topics, topic)
+			if !ContainsTopic(scan.Topic, ownTopics[schemaID]) {
+				otherTopics = append(otherTopics, topic)
+			}
+		}
+		subjects := subjectsByID[schemaID]
+		if len(subjects) < 2 && len(otherTopics) == 0 {
+			continue
+		}
+		sort.Strings(subjects)
+		shared = append(shared, SharedSchemaID{json.Number(fmt.Sprint(schemaID)), strings.Join(subjects, ", "),
+			strings.Join(topics, ", "), strings.Join(otherTopics, ", ")})
+	}
+	return shared
+}
@@ -0,0 +1,33 @@
+package pkg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSharedSchemaIDs(t *testing.T) {
+	req := require.New(t)
+	ctx := &Context{
+		Scans: []*TopicScan{
+			{TopicWithClusterInfo: TopicWithClusterInfo{"orders", "lkc-123"}, Status: SCANNED,
+				Usages: map[int32]*SchemaUsage{100002: {Type: VALUEONLY}}},
+			{TopicWithClusterInfo: TopicWithClusterInfo{"payments", "lkc-123"}, Status: SCANNED,
+				Usages: map[int32]*SchemaUsage{100001: {Type: VALUEONLY}, 100003: {Type: KEYVALUE}}},
+		},
+	}
+	schemas := []SchemaInfo{
+		{SchemaID: "100001", Subject: "orders-value", Version: "1"},
+		{SchemaID: "100002", Subject: "orders-value", Version: "2"},
+		{SchemaID: "100003", Subject: "payments-value", Version: "1"},
+	}
+	registeredSchemas := append([]SchemaInfo{
+		{SchemaID: "100003", Subject: "refunds-value", Version: "1"},
+	}, schemas...)
+
+	shared := sharedSchemaIDs(ctx, schemas, registeredSchemas)
+	req.Equal([]SharedSchemaID{
+		{"100001", "orders-value", "payments (value)", "payments (value)"},
+		{"100003", "payments-value, refunds-value", "payments (key, value)", ""},
+	}, shared)
+}
@@ -0,0 +1,110 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+)
+
+// SubjectTopics are the topics using the schemas of a subject besides the one named after it, e.g. dead letter
+// queues, retry topics, renamed topics, Kafka Streams changelogs or connector topics.
+type SubjectTopics struct {
+	Topics []string `json:"topics"`
+	// Patterns are regular expressions matching the whole name of the topics.
+	Patterns []string `json:"patterns"`
+	patterns []*regexp.Regexp
+}
+
+// LoadTopicMapping reads the extra topics of each subject, e.g.
+// {"orders-value": {"topics": ["orders-dlq"], "patterns": ["orders-app-.*-changelog"]}}.
+func LoadTopicMapping(configFile string) (map[string]*SubjectTopics, error) {
+	content, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+	var mapping map[string]*SubjectTopics
+	if err = json.Unmarshal(content, &mapping); err != nil {
+		return nil, err
+	}
+	for subject, subjectTopics := range mapping {
+		for _, pattern := range subjectTopics.Patterns {
+			compiled, err := regexp.Compile("^(?:" + pattern + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("invalid topic pattern %q of subject %s: %v", pattern, subject, err)
+			}
+			subjectTopics.patterns = append(subjectTopics.patterns, compiled)
+		}
+	}
+	return mapping, nil
+}
+
+// TopicsOf returns the names of the topics of the subjects, named after them or mapped to them.
+func (ctx *Context) TopicsOf(subjects []string) []string {
+	var topics []string
+	for _, subject := range subjects {
+		for _, topic := range ctx.subjectTopics(subject).names {
+			if !ContainsTopic(topic, topics) {
+				topics = append(topics, topic)
+			}
+		}
+	}
+	return topics
+}
+
+// subjectTopics returns the topics of a subject: the one named after it, and the ones mapped to it.
+func (ctx *Context) subjectTopics(subject string) *topicSelector {
+	selector := &topicSelector{}
+	selector.add(ExtractTopicFromSubject([]string{subject})[0])
+	if mapped, ok := ctx.TopicMapping[subject]; ok {
+		for _, topic := range mapped.Topics {
+			selector.add(topic)
+		}
+		selector.patterns = append(selector.patterns, mapped.patterns...)
+	}
+	return selector
+}
+
+// topicSelector selects topics by name or by pattern.
+type topicSelector struct {
+	names    []string
+	patterns []*regexp.Regexp
+}
+
+func (selector *topicSelector) add(topic string) {
+	if !ContainsTopic(topic, selector.names) {
+		selector.names = append(selector.names, topic)
+	}
+}
+
+func (selector *topicSelector) merge(other *topicSelector) {
+	for _, topic := range other.names {
+		selector.add(topic)
+	}
+	for _, pattern := range other.patterns {
+		if !selector.hasPattern(pattern) {
+			selector.patterns = append(selector.patterns, pattern)
+		}
+	}
+}
+
+func (selector *topicSelector) hasPattern(pattern *regexp.Regexp) bool {
+	for _, p := range selector.patterns {
+		if p.String() == pattern.String() {
+			return true
+		}
+	}
+	return false
+}
+
+func (selector *topicSelector) matches(topic string) bool {
+	if ContainsTopic(topic, selector.names) {
+		return true
+	}
+	for _, pattern := range selector.patterns {
+		if pattern.MatchString(topic) {
+			return true
+		}
+	}
+	return false
+}
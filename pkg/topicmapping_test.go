@@ -0,0 +1,37 @@
+package pkg
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopicMapping(t *testing.T) {
+	req := require.New(t)
+	file := filepath.Join(t.TempDir(), "topics.json")
+	req.NoError(ioutil.WriteFile(file, []byte(`{
+		"orders-value": {"topics": ["orders-dlq", "orders-retry"], "patterns": ["orders-app-.*-changelog"]},
+		"payments-value": {"topics": ["orders-dlq"]}
+	}`), 0644))
+	mapping, err := LoadTopicMapping(file)
+	req.NoError(err)
+
+	ctx := &Context{Clusters: []string{"lkc-123"}, Subjects: []string{"orders-key", "orders-value", "payments-value"}, TopicMapping: mapping}
+	ctx.Topics = ctx.TopicsOf(ctx.Subjects)
+	req.Equal([]string{"orders", "orders-dlq", "orders-retry", "payments"}, ctx.Topics)
+
+	clusters, topics := ctx.topicsByCluster()
+	req.Equal([]string{"lkc-123"}, clusters)
+	req.True(topics["lkc-123"].matches("orders-retry"))
+	req.True(topics["lkc-123"].matches("orders-app-store-changelog"))
+	req.False(topics["lkc-123"].matches("my-orders-app-store-changelog"))
+
+	ctx.Scans = []*TopicScan{{TopicWithClusterInfo: TopicWithClusterInfo{"orders-app-store-changelog", "lkc-123"}, Status: SCAN_FAILED}}
+	req.Equal([]string{"orders-value"}, UnknownUsageSubjects(ctx))
+
+	req.NoError(ioutil.WriteFile(file, []byte(`{"orders-value": {"patterns": ["orders-("]}}`), 0644))
+	_, err = LoadTopicMapping(file)
+	req.Error(err)
+}
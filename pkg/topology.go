@@ -0,0 +1,186 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// Topology tells which clusters have the topics of subjects whose topics are not in the clusters of the cleaned
+// up environment, e.g. clusters of another environment sharing its Schema Registry, or self-managed clusters.
+type Topology struct {
+	// Clusters describes how to reach the clusters that are not in the cleaned up environment.
+	Clusters map[string]TopologyCluster `json:"clusters"`
+	// Subjects and Contexts map subjects and schema contexts to the clusters having their topics. A subject
+	// mapping takes precedence over the mapping of its context.
+	Subjects map[string][]string `json:"subjects"`
+	Contexts map[string][]string `json:"contexts"`
+	// Exclusive tells to look for the topics of mapped subjects in the mapped clusters only, instead of in the
+	// selected clusters of the environment too.
+	Exclusive bool `json:"exclusive"`
+	// Exporters map schema exporters to the clusters of their destination, which are scanned for the topics of
+	// the exported subjects.
+	Exporters map[string][]string `json:"exporters"`
+}
+
+// TopologyCluster is either a Confluent Cloud cluster of another environment, or a self-managed cluster
+// reachable with the API key and secret of the cluster as SASL PLAIN credentials.
+type TopologyCluster struct {
+	Environment      string `json:"environment,omitempty"`
+	BootstrapServers string `json:"bootstrap_servers,omitempty"`
+}
+
+func LoadTopology(configFile string) (*Topology, error) {
+	content, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+	var topology Topology
+	if err = json.Unmarshal(content, &topology); err != nil {
+		return nil, err
+	}
+	contexts := make(map[string][]string)
+	for schemaContext, clusters := range topology.Contexts {
+		contexts[NormalizeContext(schemaContext)] = clusters
+	}
+	topology.Contexts = contexts
+	return &topology, nil
+}
+
+// subjectClusters returns the clusters having the topic of a subject, if the topology maps the subject.
+func (topology *Topology) subjectClusters(subject string) ([]string, bool) {
+	if topology == nil {
+		return nil, false
+	}
+	if clusters, ok := topology.Subjects[subject]; ok {
+		return clusters, true
+	}
+	clusters, ok := topology.Contexts[SubjectContext(subject)]
+	return clusters, ok
+}
+
+func (topology *Topology) exporterClusters(exporter string) []string {
+	if topology == nil {
+		return nil
+	}
+	return topology.Exporters[exporter]
+}
+
+func (topology *Topology) cluster(cluster string) TopologyCluster {
+	if topology == nil {
+		return TopologyCluster{}
+	}
+	return topology.Clusters[cluster]
+}
+
+// topicsByCluster returns the clusters to look for topics in, along with the topics to look for in each of them,
+// including the clusters mapped by the topology and the destination clusters of exporters.
+func (ctx *Context) topicsByCluster() ([]string, map[string]*topicSelector) {
+	var clusters []string
+	selectors := make(map[string]*topicSelector)
+	add := func(cluster string, topics *topicSelector) {
+		if _, ok := selectors[cluster]; !ok {
+			clusters = append(clusters, cluster)
+			selectors[cluster] = &topicSelector{}
+		}
+		selectors[cluster].merge(topics)
+	}
+	var mappedTopics, unmappedTopics []string
+	for _, subject := range ctx.Subjects {
+		topics := ctx.subjectTopics(subject)
+		for _, cluster := range ctx.exporterClusters(subject) {
+			add(cluster, topics)
+		}
+		mapped, ok := ctx.Topology.subjectClusters(subject)
+		for _, cluster := range mapped {
+			add(cluster, topics)
+		}
+		if ok && ctx.Topology.Exclusive {
+			mappedTopics = append(mappedTopics, topics.names...)
+			continue
+		}
+		unmappedTopics = append(unmappedTopics, topics.names...)
+		// The names are in the topics of the context, and only the patterns are left to add.
+		for _, cluster := range ctx.Clusters {
+			if len(topics.patterns) > 0 {
+				add(cluster, &topicSelector{patterns: topics.patterns})
+			}
+		}
+	}
+	for _, topic := range ctx.Topics {
+		if ContainsTopic(topic, mappedTopics) && !ContainsTopic(topic, unmappedTopics) {
+			continue
+		}
+		for _, cluster := range ctx.Clusters {
+			add(cluster, &topicSelector{names: []string{topic}})
+		}
+	}
+	// All the topics of the selected clusters are scanned, whether the subjects have topics there or not.
+	if ctx.AnyNamingStrategy() {
+		for _, cluster := range ctx.Clusters {
+			add(cluster, &topicSelector{})
+		}
+	}
+	return clusters, selectors
+}
+
+// topologyClusters returns the clusters of the topology having topics of the subjects.
+func (ctx *Context) topologyClusters() []string {
+	var clusters []string
+	for _, subject := range ctx.Subjects {
+		mapped, _ := ctx.Topology.subjectClusters(subject)
+		for _, cluster := range append(mapped, ctx.exporterClusters(subject)...) {
+			if !ContainsTopic(cluster, clusters) {
+				clusters = append(clusters, cluster)
+			}
+		}
+	}
+	return clusters
+}
+
+// withClusterEnvironment adds the environment of a cluster to the arguments of a Confluent CLI command.
+func (ctx *Context) withClusterEnvironment(cluster string, args []string) []string {
+	if environment := ctx.Topology.cluster(cluster).Environment; len(environment) > 0 {
+		return append(args, "--environment", environment)
+	}
+	return ctx.withEnvironment(args)
+}
+
+// clusterEndpoint returns the bootstrap servers of a cluster.
+func clusterEndpoint(runCtx context.Context, ctx *Context, clusterID string) (string, error) {
+	if bootstrapServers := ctx.Topology.cluster(clusterID).BootstrapServers; len(bootstrapServers) > 0 {
+		return bootstrapServers, nil
+	}
+	output, err := ExecuteCommand(runCtx, Confluent, ctx.withClusterEnvironment(clusterID, []string{"kafka", "cluster", "describe", clusterID, "-o", "json"}), false)
+	if err != nil {
+		return "", err
+	}
+	var cluster map[string]interface{}
+	if err = json.Unmarshal(output, &cluster); err != nil {
+		return "", err
+	}
+	endpoint, ok := cluster["endpoint"].(string)
+	if !ok {
+		return "", fmt.Errorf("no endpoint found for cluster %s", clusterID)
+	}
+	return endpoint, nil
+}
+
+// listBootstrapTopics lists the topics of a self-managed cluster from its metadata.
+func listBootstrapTopics(bootstrapServers string, credentials Credentials) ([]TopicName, error) {
+	consumer, err := CreateConsumer(bootstrapServers, credentials, ReadCommitted)
+	if err != nil {
+		return nil, err
+	}
+	defer CloseConsumer(consumer)
+	metadata, err := consumer.GetMetadata(nil, true, 5000)
+	if err != nil {
+		return nil, err
+	}
+	var topics []TopicName
+	for name := range metadata.Topics {
+		topics = append(topics, TopicName{name})
+	}
+	return topics, nil
+}
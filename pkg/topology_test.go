@@ -0,0 +1,52 @@
+package pkg
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopology(t *testing.T) {
+	req := require.New(t)
+	file := filepath.Join(t.TempDir(), "topology.json")
+	req.NoError(ioutil.WriteFile(file, []byte(`{
+		"clusters": {"lkc-789": {"environment": "env-456"}, "onprem": {"bootstrap_servers": "kafka:9092"}},
+		"subjects": {"payments-value": ["onprem"]},
+		"contexts": {"mycontext": ["lkc-789"]}
+	}`), 0644))
+	topology, err := LoadTopology(file)
+	req.NoError(err)
+
+	ctx := &Context{
+		Environment: "env-123",
+		Clusters:    []string{"lkc-123"},
+		Subjects:    []string{"orders-key", "orders-value", "payments-key", "payments-value", ":.mycontext:users-value"},
+		Topics:      []string{"orders", "payments", "users"},
+		Topology:    topology,
+	}
+	// Mapped clusters are looked in besides the selected clusters of the environment.
+	clusters, topics := ctx.topicsByCluster()
+	req.Equal([]string{"onprem", "lkc-789", "lkc-123"}, clusters)
+	req.Equal([]string{"orders", "payments", "users"}, topics["lkc-123"].names)
+	req.Equal([]string{"payments"}, topics["onprem"].names)
+	req.Equal([]string{"users"}, topics["lkc-789"].names)
+
+	// An exclusive topology looks for the topics of mapped subjects in the mapped clusters only.
+	topology.Exclusive = true
+	clusters, topics = ctx.topicsByCluster()
+	req.Equal([]string{"onprem", "lkc-789", "lkc-123"}, clusters)
+	req.Equal([]string{"orders", "payments"}, topics["lkc-123"].names)
+	req.Equal([]string{"payments"}, topics["onprem"].names)
+	req.Equal([]string{"users"}, topics["lkc-789"].names)
+	req.Equal([]string{"onprem", "lkc-789"}, ctx.topologyClusters())
+
+	req.Equal([]string{"--environment", "env-456"}, ctx.withClusterEnvironment("lkc-789", nil))
+	req.Equal([]string{"--environment", "env-123"}, ctx.withClusterEnvironment("lkc-123", nil))
+
+	ctx.Topology = nil
+	clusters, topics = ctx.topicsByCluster()
+	req.Equal([]string{"lkc-123"}, clusters)
+	req.Equal([]string{"orders", "payments", "users"}, topics["lkc-123"].names)
+}
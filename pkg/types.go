@@ -1,7 +1,10 @@
 package pkg
 
-import(
+import (
 	"encoding/json"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
 )
 
 const (
@@ -17,14 +20,38 @@ const (
 	KEYONLY   = 1
 	VALUEONLY = 2
 	KEYVALUE  = 3
+
+	ACTIVE       = "active"
+	SOFT_DELETED = "soft-deleted"
+
+	SCANNED          = "scanned"
+	SCAN_FAILED      = "failed"
+	SCAN_INTERRUPTED = "interrupted"
+
+	// ALL_TOPICS stands for the topics of a cluster that could not be listed when scanning all topics.
+	ALL_TOPICS = "*"
+
+	ReadCommitted   = "read_committed"
+	ReadUncommitted = "read_uncommitted"
 )
 
 var (
 	KafkaClusterFields = []interface{}{"ID", "Name", "Type", "Provider", "Region", "Availability", "Status"}
-	SchemaInfoFields   = []interface{}{"SchemaID", "Subject", "Version"}
+	SchemaInfoFields   = []interface{}{"SchemaID", "Subject", "Version", "State"}
 	TopicInfoFields    = []interface{}{"Topic", "ClusterID"}
+	TopicScanFields    = []interface{}{"Topic", "ClusterID", "Status", "Error"}
+	RecheckFields      = []interface{}{"Topic", "ClusterID", "NewMessages", "CandidatesFound"}
+
+	UncommittedUsageFields = []interface{}{"SchemaID", "Subject", "Version", "Topic", "ClusterID"}
+	SchemaIDUsageFields    = []interface{}{"SchemaID", "UsedBy"}
 )
 
+type Environment struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	IsCurrent bool   `json:"is_current"`
+}
+
 type KafkaCluster struct {
 	Availability string `json:"availability"`
 	ID           string `json:"id"`
@@ -44,10 +71,219 @@ type TopicWithClusterInfo struct {
 	ClusterID string
 }
 
+// TopicSample holds the schema IDs found in the most recent messages of a topic, or why it could not be sampled.
+type TopicSample struct {
+	TopicWithClusterInfo
+	SchemaIDs map[int32]int
+	Error     string
+}
+
+// TopicScan keeps track of what has been scanned for a topic in a Kafka cluster.
+type TopicScan struct {
+	TopicWithClusterInfo
+	Endpoint string
+	// HighWatermarks are the per-partition offsets up to which the topic has been scanned.
+	HighWatermarks map[int32]kafka.Offset
+	// Positions are the per-partition offsets the scan has reached, while it is not complete.
+	Positions map[int32]kafka.Offset
+	// Usages tells how each schema ID found in the topic is used.
+	Usages map[int32]*SchemaUsage
+	// UncommittedOnly are the usages only found in aborted or uncommitted messages, when reading uncommitted ones.
+	UncommittedOnly map[int32]int
+	// Stats counts the messages scanned, including those not framed in the Confluent wire format.
+	Stats  MessageStats
+	Status string
+	Error  string
+}
+
+// MessageStats counts the messages consumed from a topic, and those whose key or value does not start with the
+// magic byte. Null keys and values, e.g. of tombstones, are not counted as unframed.
+type MessageStats struct {
+	Messages       int64 `json:"messages"`
+	UnframedKeys   int64 `json:"unframed_keys"`
+	UnframedValues int64 `json:"unframed_values"`
+}
+
+func (stats *MessageStats) record(msg *kafka.Message) {
+	stats.Messages++
+	if len(msg.Key) > 0 && !isFramed(msg.Key) {
+		stats.UnframedKeys++
+	}
+	if len(msg.Value) > 0 && !isFramed(msg.Value) {
+		stats.UnframedValues++
+	}
+}
+
+// SchemaUsage describes how a schema ID is used in the messages of a topic.
+type SchemaUsage struct {
+	// Type tells whether the schema ID is used by message keys, values or both.
+	Type int `json:"type"`
+	// Messages is the number of messages using the schema ID, in their key, value or both. KeyMessages and
+	// ValueMessages count the messages using it in their key and value respectively.
+	Messages      int64 `json:"messages"`
+	KeyMessages   int64 `json:"key_messages"`
+	ValueMessages int64 `json:"value_messages"`
+	// FirstOffsets are the per-partition offsets of the first message using the schema ID, and tell the
+	// partitions it has been seen in.
+	FirstOffsets map[int32]kafka.Offset `json:"first_offsets"`
+	// LastKeyOffsets and LastValueOffsets are the per-partition offsets of the last message using the schema
+	// ID in its key and value respectively.
+	LastKeyOffsets   map[int32]kafka.Offset `json:"last_key_offsets"`
+	LastValueOffsets map[int32]kafka.Offset `json:"last_value_offsets"`
+	// FirstSeen and LastSeen are the oldest and newest timestamps of the messages using the schema ID, zero if
+	// none of them has a timestamp.
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	// LastKeySeen and LastValueSeen are the newest timestamps of the messages using the schema ID in their key and
+	// value respectively.
+	LastKeySeen   time.Time `json:"last_key_seen"`
+	LastValueSeen time.Time `json:"last_value_seen"`
+}
+
+func newSchemaUsage() *SchemaUsage {
+	return &SchemaUsage{
+		FirstOffsets:     make(map[int32]kafka.Offset),
+		LastKeyOffsets:   make(map[int32]kafka.Offset),
+		LastValueOffsets: make(map[int32]kafka.Offset),
+	}
+}
+
+// mergeUsages combines the usages found in two ranges of a topic into a new map.
+func mergeUsages(a, b map[int32]*SchemaUsage) map[int32]*SchemaUsage {
+	merged := make(map[int32]*SchemaUsage)
+	for _, usages := range []map[int32]*SchemaUsage{a, b} {
+		for schemaID, usage := range usages {
+			if _, ok := merged[schemaID]; !ok {
+				merged[schemaID] = newSchemaUsage()
+			}
+			merged[schemaID].merge(usage)
+		}
+	}
+	return merged
+}
+
+func (usage *SchemaUsage) clone() *SchemaUsage {
+	clone := *usage
+	clone.FirstOffsets = copyOffsets(usage.FirstOffsets)
+	clone.LastKeyOffsets = copyOffsets(usage.LastKeyOffsets)
+	clone.LastValueOffsets = copyOffsets(usage.LastValueOffsets)
+	return &clone
+}
+
+// merge adds the usages found in another range of the same topic.
+func (usage *SchemaUsage) merge(other *SchemaUsage) {
+	usage.Type = usage.Type | other.Type
+	usage.Messages += other.Messages
+	usage.KeyMessages += other.KeyMessages
+	usage.ValueMessages += other.ValueMessages
+	for partition, offset := range other.FirstOffsets {
+		if first, ok := usage.FirstOffsets[partition]; !ok || offset < first {
+			usage.FirstOffsets[partition] = offset
+		}
+	}
+	usage.seen(other.FirstSeen)
+	usage.seen(other.LastSeen)
+	usage.LastKeySeen = latest(usage.LastKeySeen, other.LastKeySeen)
+	usage.LastValueSeen = latest(usage.LastValueSeen, other.LastValueSeen)
+	for partition, offset := range other.LastKeyOffsets {
+		if last, ok := usage.LastKeyOffsets[partition]; !ok || offset > last {
+			usage.LastKeyOffsets[partition] = offset
+		}
+	}
+	for partition, offset := range other.LastValueOffsets {
+		if last, ok := usage.LastValueOffsets[partition]; !ok || offset > last {
+			usage.LastValueOffsets[partition] = offset
+		}
+	}
+}
+
+func (usage *SchemaUsage) seen(timestamp time.Time) {
+	if timestamp.IsZero() {
+		return
+	}
+	if usage.FirstSeen.IsZero() || timestamp.Before(usage.FirstSeen) {
+		usage.FirstSeen = timestamp
+	}
+	if timestamp.After(usage.LastSeen) {
+		usage.LastSeen = timestamp
+	}
+}
+
+func latest(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
+// LastOffsets returns the per-partition offsets of the last message using the schema ID in its key or value.
+func (usage *SchemaUsage) LastOffsets() map[int32]kafka.Offset {
+	lastOffsets := make(map[int32]kafka.Offset)
+	for _, offsets := range []map[int32]kafka.Offset{usage.LastKeyOffsets, usage.LastValueOffsets} {
+		for partition, offset := range offsets {
+			if last, ok := lastOffsets[partition]; !ok || offset > last {
+				lastOffsets[partition] = offset
+			}
+		}
+	}
+	return lastOffsets
+}
+
+func (usage *SchemaUsage) record(msg *kafka.Message, usageType int) {
+	usage.Type = usage.Type | usageType
+	usage.Messages++
+	if usageType&KEYONLY != 0 {
+		usage.KeyMessages++
+	}
+	if usageType&VALUEONLY != 0 {
+		usage.ValueMessages++
+	}
+	partition, offset := msg.TopicPartition.Partition, msg.TopicPartition.Offset
+	if first, ok := usage.FirstOffsets[partition]; !ok || offset < first {
+		usage.FirstOffsets[partition] = offset
+	}
+	if msg.TimestampType != kafka.TimestampNotAvailable {
+		usage.seen(msg.Timestamp)
+		if usageType&KEYONLY != 0 {
+			usage.LastKeySeen = latest(usage.LastKeySeen, msg.Timestamp)
+		}
+		if usageType&VALUEONLY != 0 {
+			usage.LastValueSeen = latest(usage.LastValueSeen, msg.Timestamp)
+		}
+	}
+	if last, ok := usage.LastKeyOffsets[partition]; usageType&KEYONLY != 0 && (!ok || offset > last) {
+		usage.LastKeyOffsets[partition] = offset
+	}
+	if last, ok := usage.LastValueOffsets[partition]; usageType&VALUEONLY != 0 && (!ok || offset > last) {
+		usage.LastValueOffsets[partition] = offset
+	}
+}
+
+type RecheckResult struct {
+	Topic           string
+	ClusterID       string
+	NewMessages     int64
+	CandidatesFound string
+}
+
+type UncommittedUsage struct {
+	SchemaID  json.Number
+	Subject   string
+	Version   json.Number
+	Topic     string
+	ClusterID string
+}
+
+type SchemaIDUsage struct {
+	SchemaID int32
+	UsedBy   string
+}
+
 type SchemaInfo struct {
 	SchemaID json.Number `json:"id"`
 	Subject  string      `json:"subject"`
 	Version  json.Number `json:"version"`
+	State    string      `json:"-"`
 }
 
 type Credentials struct {
@@ -0,0 +1,249 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	SchemaUsageFields = []interface{}{"SchemaID", "Subject", "Version", "Topic", "ClusterID", "Messages", "KeyMessages",
+		"ValueMessages", "Partitions", "Offsets", "FirstSeen", "LastSeen"}
+	SchemaCandidateFields = []interface{}{"SchemaID", "Subject", "Version", "State", "Messages", "LastUsed"}
+)
+
+// SchemaUsageStats is how a schema version is used in a topic.
+type SchemaUsageStats struct {
+	SchemaID      json.Number
+	Subject       string
+	Version       json.Number
+	Topic         string
+	ClusterID     string
+	Messages      int64
+	KeyMessages   int64
+	ValueMessages int64
+	Partitions    string
+	Offsets       string
+	FirstSeen     string
+	LastSeen      string
+}
+
+// SchemaCandidate is a schema qualifying for deletion, along with what is known of its past usage.
+type SchemaCandidate struct {
+	SchemaInfo
+	Messages int64
+	LastUsed string
+}
+
+// ReportSchemaUsage prints, for every schema version in use, how it is used in each scanned topic.
+func ReportSchemaUsage(ctx *Context, schemas []SchemaInfo) {
+	now := time.Now()
+	var stats []SchemaUsageStats
+	for _, scan := range ctx.ScannedTopics() {
+		activeSchemas := usageTypes(scan.Usages)
+		for _, schema := range schemas {
+			if !isSchemaUsed(schema, activeSchemas) {
+				continue
+			}
+			usage := scan.Usages[schemaIDOf(schema)]
+			stats = append(stats, SchemaUsageStats{schema.SchemaID, schema.Subject, schema.Version, scan.Topic,
+				scan.ClusterID, usage.Messages, usage.KeyMessages, usage.ValueMessages, formatPartitions(usage),
+				formatOffsetRanges(usage), formatSeen(usage.FirstSeen, now), formatSeen(usage.LastSeen, now)})
+		}
+	}
+	if len(stats) == 0 {
+		fmt.Println("None of the schemas is used in the scanned topics.")
+		return
+	}
+	fmt.Printf("Following %d schema usages were found in the scanned topics.\n", len(stats))
+	PrintTable(SchemaUsageFields, stats, false)
+}
+
+// describeCandidates adds to each schema the number of messages using it in the scanned topics, along with
+// when it was last used.
+func describeCandidates(ctx *Context, schemas []SchemaInfo) []SchemaCandidate {
+	now := time.Now()
+	var candidates []SchemaCandidate
+	for _, schema := range schemas {
+		messages, lastSeen := schemaUsageSummary(ctx, schema)
+		candidates = append(candidates, SchemaCandidate{schema, messages, formatSeen(lastSeen, now)})
+	}
+	return candidates
+}
+
+// schemaUsageSummary returns the number of messages using a schema in the role of its subject, along with the
+// newest timestamp of those messages.
+func schemaUsageSummary(ctx *Context, schema SchemaInfo) (int64, time.Time) {
+	var messages int64
+	var lastSeen time.Time
+	schemaID := schemaIDOf(schema)
+	for _, scan := range ctx.ScannedTopics() {
+		usage, ok := scan.Usages[schemaID]
+		if !ok {
+			continue
+		}
+		if IsKeySchema(schema.Subject) {
+			messages += usage.KeyMessages
+			lastSeen = latest(lastSeen, usage.LastKeySeen)
+		} else if IsValueSchema(schema.Subject) {
+			messages += usage.ValueMessages
+			lastSeen = latest(lastSeen, usage.LastValueSeen)
+		} else {
+			messages += usage.Messages
+			lastSeen = latest(lastSeen, usage.LastSeen)
+		}
+	}
+	return messages, lastSeen
+}
+
+func schemaIDOf(schema SchemaInfo) int32 {
+	schemaID, _ := strconv.Atoi(string(schema.SchemaID))
+	return int32(schemaID)
+}
+
+func sortedPartitions(usage *SchemaUsage) []int32 {
+	var partitions []int32
+	for partition := range usage.FirstOffsets {
+		partitions = append(partitions, partition)
+	}
+	sort.Slice(partitions, func(i, j int) bool {
+		return partitions[i] < partitions[j]
+	})
+	return partitions
+}
+
+func formatPartitions(usage *SchemaUsage) string {
+	var partitions []string
+	for _, partition := range sortedPartitions(usage) {
+		partitions = append(partitions, strconv.Itoa(int(partition)))
+	}
+	return strings.Join(partitions, ",")
+}
+
+// formatOffsetRanges tells the offsets of the first and last messages using the schema ID in each partition,
+// e.g. "0:12-340 1:7-7".
+func formatOffsetRanges(usage *SchemaUsage) string {
+	lastOffsets := usage.LastOffsets()
+	var ranges []string
+	for _, partition := range sortedPartitions(usage) {
+		ranges = append(ranges, fmt.Sprintf("%d:%d-%d", partition, usage.FirstOffsets[partition], lastOffsets[partition]))
+	}
+	return strings.Join(ranges, " ")
+}
+
+// formatSeen tells when a message was produced, e.g. "2025-08-01 (14 months ago)".
+func formatSeen(timestamp, now time.Time) string {
+	if timestamp.IsZero() {
+		return "unknown"
+	}
+	return fmt.Sprintf("%s (%s)", timestamp.Format("2006-01-02"), formatAge(now.Sub(timestamp)))
+}
+
+func formatAge(age time.Duration) string {
+	days := int(age.Hours() / 24)
+	switch {
+	case days < 1:
+		return "today"
+	case days == 1:
+		return "1 day ago"
+	case days < 60:
+		return fmt.Sprintf("%d days ago", days)
+	case days < 730:
+		return fmt.Sprintf("%d months ago", days/30)
+	default:
+		return fmt.Sprintf("%d years ago", days/365)
+	}
+}
+
+// StaleSchemas returns the schemas still in use, but only by messages older than the stale threshold. Schemas
+// used by messages without timestamps are never stale.
+func StaleSchemas(ctx *Context, schemas []SchemaInfo, usedSchemas map[int32]int) []SchemaInfo {
+	if ctx.StaleAfter <= 0 {
+		return nil
+	}
+	threshold := time.Now().Add(-ctx.StaleAfter)
+	var stale []SchemaInfo
+	for _, schema := range schemas {
+		if !isSchemaUsed(schema, usedSchemas) {
+			continue
+		}
+		if _, lastSeen := schemaUsageSummary(ctx, schema); !lastSeen.IsZero() && lastSeen.Before(threshold) {
+			stale = append(stale, schema)
+		}
+	}
+	return stale
+}
+
+// selectStaleCandidates prompts for the stale schemas to delete, which takes an extra confirmation.
+func selectStaleCandidates(ctx *Context, stale []SchemaInfo) ([]SchemaInfo, error) {
+	if len(stale) == 0 {
+		return nil, nil
+	}
+	fmt.Printf("%sStale%s: following %d schemas are still used, but only by messages older than %s. Deleting them "+
+		"breaks reading those messages.\n", RED, RESET, len(stale), ctx.StaleAfter)
+	PrintTable(SchemaCandidateFields, describeCandidates(ctx, stale), true)
+	fmt.Print("Please select the stale schemas you want to delete by typing the numbers (1st column), separated by " +
+		"comma, or press Enter to keep them all: ")
+	resp, err := ReadLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) == 0 {
+		return nil, nil
+	}
+	selection, err := parseSelection(resp, stale)
+	if err != nil {
+		return nil, err
+	}
+	PrintTable(SchemaCandidateFields, describeCandidates(ctx, selection), true)
+	confirmed, err := confirmStale("deletion", len(selection))
+	if err != nil || !confirmed {
+		return nil, err
+	}
+	return selection, nil
+}
+
+// confirmStaleHardDeletion asks again before hard deleting the schemas selected as stale, as the old messages
+// using them can no longer be read afterwards. Stale schemas that are not confirmed are left out.
+func confirmStaleHardDeletion(ctx *Context, schemas, stale []SchemaInfo) ([]SchemaInfo, error) {
+	var selectedStale, others []SchemaInfo
+	for _, schema := range schemas {
+		if containsVersion(stale, schema.Subject, schema.Version) {
+			selectedStale = append(selectedStale, schema)
+		} else {
+			others = append(others, schema)
+		}
+	}
+	if len(selectedStale) == 0 {
+		return schemas, nil
+	}
+	fmt.Printf("%sStale%s: following %d schemas are still used by old messages, which can no longer be read once "+
+		"the schemas are hard deleted.\n", RED, RESET, len(selectedStale))
+	PrintTable(SchemaCandidateFields, describeCandidates(ctx, selectedStale), true)
+	confirmed, err := confirmStale("hard deletion", len(selectedStale))
+	if err != nil {
+		return nil, err
+	}
+	if !confirmed {
+		return others, nil
+	}
+	return schemas, nil
+}
+
+func confirmStale(action string, count int) (bool, error) {
+	fmt.Printf("Confirm %s of above %sstale%s schemas still used by old messages by typing the number of "+
+		"selected schemas (%d): %s", action, RED, RESET, count, RED)
+	resp, err := ReadLine()
+	ResetColor()
+	if err != nil {
+		return false, err
+	}
+	if resp != strconv.Itoa(count) {
+		fmt.Println("Stale schemas not confirmed, keeping them.")
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,132 @@
+package pkg
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatSeen(t *testing.T) {
+	req := require.New(t)
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	req.Equal("unknown", formatSeen(time.Time{}, now))
+	req.Equal("2026-10-01 (today)", formatSeen(now.Add(-time.Hour), now))
+	req.Equal("2026-09-30 (1 day ago)", formatSeen(now.Add(-24*time.Hour), now))
+	req.Equal("2025-08-01 (14 months ago)", formatSeen(time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC), now))
+	req.Equal("2022-10-01 (4 years ago)", formatSeen(time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC), now))
+}
+
+func TestStaleSchemas(t *testing.T) {
+	req := require.New(t)
+	now := time.Now()
+	ctx := &Context{
+		StaleAfter: 365 * 24 * time.Hour,
+		Scans: []*TopicScan{
+			{TopicWithClusterInfo: TopicWithClusterInfo{"orders", "lkc-123"}, Status: SCANNED, Usages: map[int32]*SchemaUsage{
+				100001: {Type: KEYVALUE, Messages: 4, KeyMessages: 1, ValueMessages: 3, LastSeen: now.Add(-time.Hour),
+					LastKeySeen: now.Add(-time.Hour), LastValueSeen: now.Add(-2 * 365 * 24 * time.Hour)},
+				100002: {Type: VALUEONLY, Messages: 1, ValueMessages: 1, LastSeen: now.Add(-time.Hour), LastValueSeen: now.Add(-time.Hour)},
+				100003: {Type: VALUEONLY, Messages: 1, ValueMessages: 1},
+			}},
+		},
+	}
+	schemas := []SchemaInfo{
+		{SchemaID: "100001", Subject: "orders-value", Version: "1"},
+		{SchemaID: "100002", Subject: "orders-value", Version: "2"},
+		{SchemaID: "100003", Subject: "orders-value", Version: "3"},
+		{SchemaID: "100004", Subject: "orders-value", Version: "4"},
+	}
+	usedSchemas := map[int32]int{100001: KEYVALUE, 100002: VALUEONLY, 100003: VALUEONLY}
+	req.Equal(schemas[:1], StaleSchemas(ctx, schemas, usedSchemas))
+
+	// A recent use of the schema ID in keys does not keep it from being stale as a value schema, and vice versa.
+	keySchema := SchemaInfo{SchemaID: "100001", Subject: "orders-key", Version: "1"}
+	req.Empty(StaleSchemas(ctx, []SchemaInfo{keySchema}, usedSchemas))
+
+	candidates := describeCandidates(ctx, schemas[:1])
+	req.Equal(int64(3), candidates[0].Messages)
+	req.Contains(candidates[0].LastUsed, "2 years ago")
+
+	ctx.StaleAfter = 0
+	req.Empty(StaleSchemas(ctx, schemas, usedSchemas))
+}
+
+func TestSchemaUsageRecord(t *testing.T) {
+	req := require.New(t)
+	topic := "my-topic"
+	usage := newSchemaUsage()
+	usage.record(&kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 0, Offset: 0}}, KEYONLY)
+	usage.record(&kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 1, Offset: 5}}, KEYVALUE)
+	usage.record(&kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 1, Offset: 3}}, VALUEONLY)
+	req.Equal(KEYVALUE, usage.Type)
+	req.Equal(map[int32]kafka.Offset{0: 0, 1: 5}, usage.LastKeyOffsets)
+	req.Equal(map[int32]kafka.Offset{1: 5}, usage.LastValueOffsets)
+}
+
+func TestParseSelection(t *testing.T) {
+	req := require.New(t)
+	candidates := []SchemaInfo{
+		{SchemaID: "100001", Subject: "orders-value", Version: "1"},
+		{SchemaID: "100002", Subject: "orders-value", Version: "2"},
+	}
+	selection, err := parseSelection("1, 0", candidates)
+	req.NoError(err)
+	req.Equal([]SchemaInfo{candidates[1], candidates[0]}, selection)
+	_, err = parseSelection("2", candidates)
+	req.Error(err)
+	_, err = parseSelection("one", candidates)
+	req.Error(err)
+}
+
+// withStdin makes the prompts read the given input.
+func withStdin(t *testing.T, input string) {
+	file, err := ioutil.TempFile(t.TempDir(), "stdin")
+	require.NoError(t, err)
+	_, err = file.WriteString(input)
+	require.NoError(t, err)
+	_, err = file.Seek(0, 0)
+	require.NoError(t, err)
+	stdin := os.Stdin
+	os.Stdin = file
+	t.Cleanup(func() {
+		os.Stdin = stdin
+		file.Close()
+	})
+}
+
+func TestConfirmStaleHardDeletion(t *testing.T) {
+	req := require.New(t)
+	ctx := &Context{
+		Scans: []*TopicScan{
+			{TopicWithClusterInfo: TopicWithClusterInfo{"orders", "lkc-123"}, Status: SCANNED, Usages: map[int32]*SchemaUsage{
+				100001: {Type: VALUEONLY, Messages: 3, ValueMessages: 3},
+			}},
+		},
+	}
+	schemas := []SchemaInfo{
+		{SchemaID: "100001", Subject: "orders-value", Version: "1"},
+		{SchemaID: "100002", Subject: "orders-value", Version: "2"},
+	}
+	withStdin(t, "y\n")
+	selection, err := confirmStaleHardDeletion(ctx, schemas, schemas[:1])
+	req.NoError(err)
+	req.Equal(schemas[1:], selection)
+
+	withStdin(t, "1\n")
+	selection, err = confirmStaleHardDeletion(ctx, schemas, schemas[:1])
+	req.NoError(err)
+	req.Equal(schemas, selection)
+
+	selection, err = confirmStaleHardDeletion(ctx, schemas[1:], schemas[:1])
+	req.NoError(err)
+	req.Equal(schemas[1:], selection)
+
+	// Used schemas selected as behind all consumers are not stale, and take no extra confirmation.
+	selection, err = confirmStaleHardDeletion(ctx, schemas, nil)
+	req.NoError(err)
+	req.Equal(schemas, selection)
+}
@@ -3,11 +3,13 @@ package pkg
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"reflect"
+	"sort"
 	"strings"
 
 	"github.com/jedib0t/go-pretty/v6/table"
@@ -17,10 +19,14 @@ import (
 const (
 	CONTEXT_PREFIX = ":."
 	CONTEXT_SUFFIX = ":"
+
+	DEFAULT_CONTEXT = "."
+	// ALL_CONTEXTS_PREFIX is the subject prefix matching the subjects of every schema context.
+	ALL_CONTEXTS_PREFIX = ":*:"
 )
 
-func ExecuteCommand(name string, args []string, silence bool) ([]byte, error) {
-	cmd := exec.Command(name, args...)
+func ExecuteCommand(runCtx context.Context, name string, args []string, silence bool) ([]byte, error) {
+	cmd := exec.CommandContext(runCtx, name, args...)
 	var oBuffer bytes.Buffer
 	cmd.Stdout = &oBuffer
 	if !silence {
@@ -33,6 +39,12 @@ func ExecuteCommand(name string, args []string, silence bool) ([]byte, error) {
 	return oBuffer.Bytes(), nil
 }
 
+// credentialsError is returned when a Schema Registry command fails, most likely for lack of credentials.
+func credentialsError(action string) error {
+	return fmt.Errorf(`error while %s. Check you have proper credentials stored by running any Schema Registry `+
+		`command, e.g. "confluent schema-registry subject list"`, action)
+}
+
 func IsKeySchema(subject string) bool {
 	return strings.HasSuffix(subject, "-key")
 }
@@ -66,7 +78,57 @@ func ExtractTopicFromSubject(subjects []string) []string {
 	return topics
 }
 
+// SubjectContext returns the schema context of a subject, e.g. ".mycontext" for ":.mycontext:test-value", or
+// DEFAULT_CONTEXT for subjects of the default context.
+func SubjectContext(subject string) string {
+	if !strings.HasPrefix(subject, CONTEXT_PREFIX) {
+		return DEFAULT_CONTEXT
+	}
+	idx := strings.Index(subject[len(CONTEXT_PREFIX):], CONTEXT_SUFFIX)
+	if idx <= 0 {
+		return DEFAULT_CONTEXT
+	}
+	return subject[len(CONTEXT_PREFIX)-1 : len(CONTEXT_PREFIX)+idx]
+}
+
+// NormalizeContext returns the name of a schema context with its leading dot, e.g. ".mycontext" for "mycontext".
+func NormalizeContext(name string) string {
+	if len(name) == 0 || name == DEFAULT_CONTEXT {
+		return DEFAULT_CONTEXT
+	}
+	return DEFAULT_CONTEXT + strings.TrimPrefix(name, DEFAULT_CONTEXT)
+}
+
+// ContextPrefix returns the prefix of the subjects in a schema context, empty for the default context.
+func ContextPrefix(schemaContext string) string {
+	if schemaContext == DEFAULT_CONTEXT {
+		return ""
+	}
+	return ":" + schemaContext + CONTEXT_SUFFIX
+}
+
+// GroupSubjectsByContext groups the subjects by schema context, and returns the contexts in order.
+func GroupSubjectsByContext(subjects []string) ([]string, map[string][]string) {
+	var contexts []string
+	groups := make(map[string][]string)
+	for _, subject := range subjects {
+		schemaContext := SubjectContext(subject)
+		if _, ok := groups[schemaContext]; !ok {
+			contexts = append(contexts, schemaContext)
+		}
+		groups[schemaContext] = append(groups[schemaContext], subject)
+	}
+	sort.Strings(contexts)
+	return contexts, groups
+}
+
 func ValidateParams(cmd *cobra.Command) (string, bool, error) {
+	if cmd.Flags().Changed("finalize") {
+		if cmd.Flags().Changed("all") || cmd.Flags().Changed("subject") || cmd.Flags().Changed("all-contexts") {
+			return "", false, errors.New("--finalize takes subjects from the quarantine state and cannot be used with --subject, --all or --all-contexts")
+		}
+		return "", false, nil
+	}
 	if !cmd.Flags().Changed("all") && !cmd.Flags().Changed("subject") {
 		return "", false, errors.New("at least one of --subject or --all must be specified")
 	}
@@ -79,8 +141,20 @@ func ValidateParams(cmd *cobra.Command) (string, bool, error) {
 		if err != nil {
 			return "", false, err
 		}
-		if !VerifySubject(subject) {
-			return "", false, errors.New("only subjects from TopicNameStrategy is supported")
+		anyNamingStrategy := cmd.Flags().Changed("scan-all-topics") || cmd.Flags().Changed("usage-index")
+		if !VerifySubject(subject) && !anyNamingStrategy {
+			return "", false, errors.New("only subjects from TopicNameStrategy is supported, unless scanning all topics with --scan-all-topics or --usage-index")
+		}
+		if cmd.Flags().Changed("all-contexts") {
+			return "", false, errors.New("--all-contexts can only be used with --all")
+		}
+		schemaContext, err := cmd.Flags().GetString("context")
+		if err != nil {
+			return "", false, err
+		}
+		if strings.HasPrefix(subject, CONTEXT_PREFIX) && cmd.Flags().Changed("context") &&
+			SubjectContext(subject) != NormalizeContext(schemaContext) {
+			return "", false, fmt.Errorf("subject %s is not in schema context %s", subject, NormalizeContext(schemaContext))
 		}
 	}
 	subject, err := cmd.Flags().GetString("subject")
@@ -3,8 +3,11 @@ package pkg
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/require"
 )
 
@@ -98,3 +101,65 @@ func TestPrintTable(t *testing.T) {
 		"+---+---------+-----------+\n", string(out))
 	os.Stdout = old
 }
+
+func TestSchemaContexts(t *testing.T) {
+	req := require.New(t)
+	req.Equal(DEFAULT_CONTEXT, SubjectContext("test-value"))
+	req.Equal(DEFAULT_CONTEXT, SubjectContext(":.:test-value"))
+	req.Equal(".mycontext", SubjectContext(":.mycontext:test-value"))
+	req.Equal(".mycontext", NormalizeContext("mycontext"))
+	req.Equal(".mycontext", NormalizeContext(".mycontext"))
+	req.Equal(DEFAULT_CONTEXT, NormalizeContext(""))
+	req.Equal("", ContextPrefix(DEFAULT_CONTEXT))
+	req.Equal(":.mycontext:", ContextPrefix(".mycontext"))
+
+	contexts, groups := GroupSubjectsByContext([]string{":.b:test-value", "test-key", ":.a:test-value", ":.b:test-key"})
+	req.Equal([]string{".", ".a", ".b"}, contexts)
+	req.Equal([]string{":.b:test-value", ":.b:test-key"}, groups[".b"])
+	req.Equal([]string{"test-key"}, groups[DEFAULT_CONTEXT])
+}
+
+func TestValidateParams(t *testing.T) {
+	req := require.New(t)
+	validate := func(args ...string) error {
+		cmd := &cobra.Command{}
+		cmd.Flags().String("subject", "", "")
+		cmd.Flags().String("context", "", "")
+		cmd.Flags().Bool("all", false, "")
+		cmd.Flags().Bool("all-contexts", false, "")
+		cmd.Flags().Bool("finalize", false, "")
+		cmd.Flags().Bool("scan-all-topics", false, "")
+		cmd.Flags().String("usage-index", "", "")
+		req.NoError(cmd.Flags().Parse(args))
+		_, _, err := ValidateParams(cmd)
+		return err
+	}
+	req.NoError(validate("--finalize"))
+	req.Error(validate("--finalize", "--all-contexts"))
+	req.NoError(validate("--subject", ":.mycontext:orders-value", "--context", "mycontext"))
+	req.NoError(validate("--subject", ":.mycontext:orders-value"))
+	req.Error(validate("--subject", ":.mycontext:orders-value", "--context", "other"))
+	req.Error(validate("--subject", ":.mycontext:orders-value", "--context", "."))
+}
+
+// fakeConfluent puts first on the PATH a confluent command that logs its arguments, one command per line, then
+// runs the given shell script. It returns the logged commands.
+func fakeConfluent(t *testing.T, script string) func() []string {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "commands.log")
+	content := "#!/bin/sh\necho \"$*\" >> " + log + "\n" + script + "\n"
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, Confluent), []byte(content), 0755))
+	path := os.Getenv("PATH")
+	require.NoError(t, os.Setenv("PATH", dir+string(os.PathListSeparator)+path))
+	t.Cleanup(func() {
+		_ = os.Setenv("PATH", path)
+	})
+	return func() []string {
+		content, err := ioutil.ReadFile(log)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		require.NoError(t, err)
+		return strings.Split(strings.TrimSpace(string(content)), "\n")
+	}
+}
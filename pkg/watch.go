@@ -0,0 +1,228 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+var WatchAlertFields = []interface{}{"Topic", "ClusterID", "Partition", "Offset", "SchemaID", "Subject", "Version", "RolledBack"}
+
+type WatchAlert struct {
+	Topic      string
+	ClusterID  string
+	Partition  int32
+	Offset     kafka.Offset
+	SchemaID   json.Number
+	Subject    string
+	Version    json.Number
+	RolledBack bool
+}
+
+// SchemaDefinition is the part of a registered schema needed to register it again.
+type SchemaDefinition struct {
+	Schema     string          `json:"schema"`
+	Type       string          `json:"type"`
+	References json.RawMessage `json:"references"`
+}
+
+// WatchDeletedSchemas tails the scanned topics for the watch window, alerting on the deleted schemas that show
+// up, and returns the schemas that are safe to hard delete.
+func WatchDeletedSchemas(runCtx context.Context, ctx *Context, deleted []SchemaInfo) ([]SchemaInfo, error) {
+	if ctx.WatchWindow <= 0 || len(deleted) == 0 {
+		return deleted, nil
+	}
+	fmt.Printf("Watching the scanned topics for %s for new messages using the deleted schemas...\n", ctx.WatchWindow)
+	var consumers []*kafka.Consumer
+	defer func() {
+		for _, consumer := range consumers {
+			CloseConsumer(consumer)
+		}
+	}()
+	scans := ctx.ScannedTopics()
+	for _, scan := range scans {
+		consumer, err := CreateConsumer(scan.Endpoint, ctx.Credentials[scan.ClusterID], ctx.IsolationLevel)
+		if err != nil {
+			return nil, err
+		}
+		consumers = append(consumers, consumer)
+		if err = assignFromWatermarks(consumer, scan.Topic, scan.HighWatermarks); err != nil {
+			return nil, err
+		}
+	}
+
+	watch := newDeletionWatch(ctx, deleted)
+	deadline := time.Now().Add(ctx.WatchWindow)
+	// Once every deleted schema has shown up, and been registered again if enabled, watching longer changes nothing.
+	for time.Now().Before(deadline) && !watch.done() {
+		if runCtx.Err() != nil {
+			return nil, runCtx.Err()
+		}
+		for i, consumer := range consumers {
+			switch e := consumer.Poll(100).(type) {
+			case *kafka.Message:
+				if err := watch.record(runCtx, scans[i], e); err != nil {
+					return nil, err
+				}
+			case kafka.Error:
+				if e.IsFatal() {
+					return nil, e
+				}
+			}
+		}
+	}
+
+	safe := watch.safe()
+	if len(watch.alerts) == 0 {
+		fmt.Printf("%sVerdict: none of the deleted schemas was used during the watch window, "+
+			"all of them can be hard deleted.%s\n", GREEN, RESET)
+		return safe, nil
+	}
+	PrintTable(WatchAlertFields, watch.alerts, false)
+	fmt.Printf("%sVerdict: %d of the deleted schemas were used during the watch window and will not be hard deleted.%s\n",
+		RED, len(deleted)-len(safe), RESET)
+	return safe, nil
+}
+
+type watchedUsage struct {
+	schema SchemaInfo
+	topic  TopicWithClusterInfo
+}
+
+type deletionWatch struct {
+	ctx         *Context
+	deleted     []SchemaInfo
+	usedSchemas map[int32]int
+	alerted     map[watchedUsage]bool
+	alerts      []WatchAlert
+}
+
+func newDeletionWatch(ctx *Context, deleted []SchemaInfo) *deletionWatch {
+	return &deletionWatch{
+		ctx:         ctx,
+		deleted:     deleted,
+		usedSchemas: make(map[int32]int),
+		alerted:     make(map[watchedUsage]bool),
+	}
+}
+
+// record alerts about the deleted schemas used by a message, once per schema and topic, and registers them again
+// the first time they show up if auto rollback is enabled.
+func (watch *deletionWatch) record(runCtx context.Context, scan *TopicScan, msg *kafka.Message) error {
+	for schemaID, usage := range decodeSchemaIDs(msg) {
+		for _, schema := range watch.deleted {
+			if !isSchemaUsed(schema, map[int32]int{schemaID: usage}) {
+				continue
+			}
+			key := watchedUsage{schema, scan.TopicWithClusterInfo}
+			if watch.alerted[key] {
+				continue
+			}
+			watch.alerted[key] = true
+			alert := WatchAlert{scan.Topic, scan.ClusterID, msg.TopicPartition.Partition,
+				msg.TopicPartition.Offset, schema.SchemaID, schema.Subject, schema.Version, false}
+			fmt.Printf("%sDeleted schema %s (version %s of subject %s) used at offset %d of partition %d "+
+				"of topic %s in cluster %s.%s\n", RED, schema.SchemaID, schema.Version, schema.Subject,
+				alert.Offset, alert.Partition, alert.Topic, alert.ClusterID, RESET)
+			if watch.ctx.AutoRollback && !isSchemaUsed(schema, watch.usedSchemas) {
+				if err := restoreSchema(runCtx, watch.ctx, schema); err != nil {
+					return err
+				}
+				alert.RolledBack = true
+				fmt.Printf("Registered schema %s under subject %s again.\n", schema.SchemaID, schema.Subject)
+			}
+			watch.alerts = append(watch.alerts, alert)
+		}
+		watch.usedSchemas[schemaID] = watch.usedSchemas[schemaID] | usage
+	}
+	return nil
+}
+
+func (watch *deletionWatch) done() bool {
+	return len(watch.safe()) == 0
+}
+
+// safe returns the deleted schemas that have not shown up yet.
+func (watch *deletionWatch) safe() []SchemaInfo {
+	var safe []SchemaInfo
+	for _, schema := range watch.deleted {
+		if !isSchemaUsed(schema, watch.usedSchemas) {
+			safe = append(safe, schema)
+		}
+	}
+	return safe
+}
+
+func assignFromWatermarks(consumer *kafka.Consumer, topic string, highWatermarks map[int32]kafka.Offset) error {
+	partitions, err := getPartitions(consumer, topic)
+	if err != nil {
+		return err
+	}
+	var tpl []kafka.TopicPartition
+	var topicName = topic
+	for _, partition := range partitions {
+		offset, ok := highWatermarks[partition]
+		if !ok {
+			offset = kafka.OffsetBeginning
+		}
+		tpl = append(tpl, kafka.TopicPartition{
+			Topic:     &topicName,
+			Partition: partition,
+			Offset:    offset,
+		})
+	}
+	return consumer.Assign(tpl)
+}
+
+// restoreSchema registers a soft deleted schema under its subject again. It keeps its schema ID but gets a new
+// version number.
+func restoreSchema(runCtx context.Context, ctx *Context, schema SchemaInfo) error {
+	output, err := ExecuteCommand(runCtx, Confluent, ctx.withEnvironment([]string{"schema-registry", "schema", "describe", string(schema.SchemaID), "-o", "json"}), false)
+	if err != nil {
+		return err
+	}
+	var definition SchemaDefinition
+	if err = json.Unmarshal(output, &definition); err != nil {
+		return err
+	}
+	schemaFile, err := writeTempFile("schema", []byte(definition.Schema))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(schemaFile)
+
+	schemaType := strings.ToLower(definition.Type)
+	if len(schemaType) == 0 {
+		schemaType = "avro"
+	}
+	args := []string{"schema-registry", "schema", "create", "--subject", schema.Subject, "--schema", schemaFile, "--type", schemaType}
+	if refs := strings.TrimSpace(string(definition.References)); len(refs) > 0 && refs != "null" && refs != "[]" {
+		referencesFile, err := writeTempFile("references", definition.References)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(referencesFile)
+		args = append(args, "--references", referencesFile)
+	}
+	// Like deletions, registering the schema again is not killed midway when the run is cancelled.
+	_, err = ExecuteCommand(context.Background(), Confluent, ctx.withEnvironment(args), false)
+	return err
+}
+
+func writeTempFile(prefix string, content []byte) (string, error) {
+	file, err := ioutil.TempFile("", prefix)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if _, err = file.Write(content); err != nil {
+		return "", err
+	}
+	return file.Name(), nil
+}
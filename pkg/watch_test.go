@@ -0,0 +1,40 @@
+package pkg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeletionWatch(t *testing.T) {
+	req := require.New(t)
+	deleted := []SchemaInfo{
+		{SchemaID: "100001", Subject: "orders-value", Version: "1"},
+		{SchemaID: "100002", Subject: "orders-value", Version: "2"},
+	}
+	watch := newDeletionWatch(&Context{}, deleted)
+	orders := &TopicScan{TopicWithClusterInfo: TopicWithClusterInfo{"orders", "lkc-123"}}
+	message := func(offset kafka.Offset, value []byte) *kafka.Message {
+		return &kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &orders.Topic, Offset: offset}, Value: value}
+	}
+
+	// Every message using a deleted schema in the same topic makes a single alert.
+	req.NoError(watch.record(context.Background(), orders, message(10, []byte{0, 0, 1, 134, 161, 'v'})))
+	req.NoError(watch.record(context.Background(), orders, message(11, []byte{0, 0, 1, 134, 161, 'v'})))
+	req.Len(watch.alerts, 1)
+	req.Equal(kafka.Offset(10), watch.alerts[0].Offset)
+	req.Equal(deleted[1:], watch.safe())
+	req.False(watch.done())
+
+	// The same schema showing up in another topic is alerted about again.
+	payments := &TopicScan{TopicWithClusterInfo: TopicWithClusterInfo{"payments", "lkc-123"}}
+	req.NoError(watch.record(context.Background(), payments, message(3, []byte{0, 0, 1, 134, 161, 'v'})))
+	req.Len(watch.alerts, 2)
+
+	req.NoError(watch.record(context.Background(), orders, message(12, []byte{0, 0, 1, 134, 162, 'v'})))
+	req.Len(watch.alerts, 3)
+	req.Empty(watch.safe())
+	req.True(watch.done())
+}